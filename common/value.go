@@ -5,16 +5,10 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-var URL string
-
 var Spinner = spinner.New(
 	spinner.WithSpinner(spinner.MiniDot),
 )
 
-var UserName string
-
-var Token string
-
 type Model[T any] interface {
 	Init() tea.Cmd
 	Update(msg tea.Msg) (T, tea.Cmd)
@@ -0,0 +1,243 @@
+// Package config loads the client settings.
+//
+// Settings come from, in increasing order of precedence: the defaults, the
+// TOML file at $XDG_CONFIG_HOME/chatt/config.toml, CHATT_* environment
+// variables and command line flags.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/onfirebyte/chatt/design"
+	"github.com/onfirebyte/chatt/request"
+	"github.com/onfirebyte/chatt/store"
+)
+
+// Config is everything the client can be configured with.
+type Config struct {
+	// Server is the URL of the chatt server; the scheme defaults to http.
+	Server string `toml:"server"`
+	// User fills in the name on the login screen.
+	User string `toml:"user"`
+	// LogFile receives debug logs; nothing is logged when empty.
+	LogFile string `toml:"log_file"`
+	// Theme is one of design.Themes.
+	Theme string `toml:"theme"`
+
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string `toml:"ca_file"`
+	// CertFile and KeyFile are a client certificate for mutual TLS.
+	CertFile string `toml:"cert_file"`
+	KeyFile  string `toml:"key_file"`
+	// InsecureSkipVerify accepts any server certificate, for development.
+	InsecureSkipVerify bool `toml:"insecure_skip_verify"`
+	// Proxy is an http:// or socks5:// URL; HTTPS_PROXY is used when empty.
+	Proxy string `toml:"proxy"`
+
+	// Markdown renders message formatting.
+	Markdown bool `toml:"markdown"`
+	// ComposerHeight is how many rows the message composer grows to before
+	// it scrolls.
+	ComposerHeight int `toml:"composer_height"`
+	// StoreLimit is how many messages per conversation are kept on disk;
+	// zero turns the local store off.
+	StoreLimit int `toml:"store_limit"`
+	// E2E encrypts direct messages end to end.
+	E2E bool `toml:"e2e"`
+	// StoreEncrypted keeps decrypted direct messages in the local store,
+	// where they are no longer encrypted.
+	StoreEncrypted bool `toml:"store_encrypted"`
+}
+
+// Default returns the settings used when nothing else is configured.
+func Default() Config {
+	return Config{
+		Theme:          "auto",
+		Markdown:       true,
+		ComposerHeight: design.ComposerHeight,
+		StoreLimit:     store.DefaultLimit,
+	}
+}
+
+// Path returns where the config file is looked up.
+func Path() (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "chatt", "config.toml"), nil
+}
+
+// ReadFile sets the keys present in the TOML file at path. Unknown keys are
+// an error, so a typo does not go unnoticed.
+func (c *Config) ReadFile(path string) error {
+	md, err := toml.DecodeFile(path, c)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return fmt.Errorf("%s: unknown key %s", path, strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// ApplyEnv overrides the settings with the CHATT_* environment variables
+// that are set. DEBUG is still honoured and logs to debug.log.
+func (c *Config) ApplyEnv() error {
+	if v, ok := os.LookupEnv("CHATT_SERVER"); ok {
+		c.Server = v
+	}
+	if v, ok := os.LookupEnv("CHATT_USER"); ok {
+		c.User = v
+	}
+	if v, ok := os.LookupEnv("CHATT_LOG_FILE"); ok {
+		c.LogFile = v
+	} else if os.Getenv("DEBUG") != "" && c.LogFile == "" {
+		c.LogFile = "debug.log"
+	}
+	if v, ok := os.LookupEnv("CHATT_THEME"); ok {
+		c.Theme = v
+	}
+	if v, ok := os.LookupEnv("CHATT_CA_FILE"); ok {
+		c.CAFile = v
+	}
+	if v, ok := os.LookupEnv("CHATT_CERT_FILE"); ok {
+		c.CertFile = v
+	}
+	if v, ok := os.LookupEnv("CHATT_KEY_FILE"); ok {
+		c.KeyFile = v
+	}
+	if v, ok := os.LookupEnv("CHATT_INSECURE_SKIP_VERIFY"); ok {
+		on, err := parseSwitch(v)
+		if err != nil {
+			return fmt.Errorf("CHATT_INSECURE_SKIP_VERIFY: %w", err)
+		}
+		c.InsecureSkipVerify = on
+	}
+	if v, ok := os.LookupEnv("CHATT_PROXY"); ok {
+		c.Proxy = v
+	}
+	if v, ok := os.LookupEnv("CHATT_MARKDOWN"); ok {
+		on, err := parseSwitch(v)
+		if err != nil {
+			return fmt.Errorf("CHATT_MARKDOWN: %w", err)
+		}
+		c.Markdown = on
+	}
+	if v, ok := os.LookupEnv("CHATT_COMPOSER_HEIGHT"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("CHATT_COMPOSER_HEIGHT: want a number of rows, got %q", v)
+		}
+		c.ComposerHeight = n
+	}
+	if v, ok := os.LookupEnv("CHATT_STORE"); ok {
+		on, err := parseSwitch(v)
+		if err != nil {
+			return fmt.Errorf("CHATT_STORE: %w", err)
+		}
+		if !on {
+			c.StoreLimit = 0
+		}
+	}
+	if v, ok := os.LookupEnv("CHATT_E2E"); ok {
+		on, err := parseSwitch(v)
+		if err != nil {
+			return fmt.Errorf("CHATT_E2E: %w", err)
+		}
+		c.E2E = on
+	}
+	if v, ok := os.LookupEnv("CHATT_STORE_ENCRYPTED"); ok {
+		on, err := parseSwitch(v)
+		if err != nil {
+			return fmt.Errorf("CHATT_STORE_ENCRYPTED: %w", err)
+		}
+		c.StoreEncrypted = on
+	}
+	return nil
+}
+
+// Validate checks the settings and normalizes the server URL.
+func (c *Config) Validate() error {
+	var errs []error
+
+	server, err := ServerURL(c.Server)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	c.Server = server
+
+	if !slices.Contains(design.Themes, c.Theme) {
+		errs = append(errs, fmt.Errorf("unknown theme %q, want one of %s", c.Theme, strings.Join(design.Themes, ", ")))
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		errs = append(errs, errors.New("cert_file and key_file must be set together"))
+	}
+	if c.Proxy != "" {
+		if err := request.CheckProxy(c.Proxy); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.ComposerHeight < 1 {
+		errs = append(errs, fmt.Errorf("composer_height must be at least 1, got %d", c.ComposerHeight))
+	}
+	if c.StoreLimit < 0 {
+		errs = append(errs, fmt.Errorf("store_limit cannot be negative, got %d", c.StoreLimit))
+	}
+	return errors.Join(errs...)
+}
+
+// ServerURL turns what the user typed, such as "localhost:8080", into the
+// base URL of the server.
+func ServerURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("no server given, pass --server or set server in the config file")
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid server URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid server URL %q: missing host", raw)
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+// parseSwitch reads an on/off environment variable.
+func parseSwitch(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "on", "yes":
+		return true, nil
+	case "off", "no":
+		return false, nil
+	}
+	on, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("want on or off, got %q", v)
+	}
+	return on, nil
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Session is a login remembered between launches.
+type Session struct {
+	User         string `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+// sessionsMu serializes the read-modify-write of the credentials file.
+var sessionsMu sync.Mutex
+
+// SessionsPath returns the credentials file, next to the config file. It
+// holds one session per server URL and is only readable by its owner.
+func SessionsPath() (string, error) {
+	path, err := Path()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "credentials.json"), nil
+}
+
+// LoadSession returns the session remembered for server, if any.
+func LoadSession(server string) (Session, bool, error) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	sessions, _, err := readSessions()
+	if err != nil {
+		return Session{}, false, err
+	}
+	s, ok := sessions[server]
+	return s, ok, nil
+}
+
+// SaveSession remembers s for server, replacing the previous session.
+func SaveSession(server string, s Session) error {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	sessions, path, err := readSessions()
+	if err != nil {
+		return err
+	}
+	sessions[server] = s
+	return writeSessions(path, sessions)
+}
+
+// DeleteSession forgets the session of server.
+func DeleteSession(server string) error {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	sessions, path, err := readSessions()
+	if err != nil {
+		return err
+	}
+	if _, ok := sessions[server]; !ok {
+		return nil
+	}
+	delete(sessions, server)
+	return writeSessions(path, sessions)
+}
+
+func readSessions() (map[string]Session, string, error) {
+	path, err := SessionsPath()
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessions := map[string]Session{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return sessions, path, nil
+	} else if err != nil {
+		return nil, "", err
+	}
+	if err := json.Unmarshal(b, &sessions); err != nil {
+		return nil, "", err
+	}
+	return sessions, path, nil
+}
+
+// writeSessions replaces the file through a rename, so a crash never leaves
+// it half written.
+func writeSessions(path string, sessions map[string]Session) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".credentials-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	// CreateTemp already makes the file 0600.
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
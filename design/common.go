@@ -7,6 +7,11 @@ var (
 	Highlight = lip.AdaptiveColor{Light: "#874BFD", Dark: "#7D56F4"}
 	Special   = lip.AdaptiveColor{Light: "#43BF6D", Dark: "#73F59F"}
 	Error     = lip.AdaptiveColor{Light: "#FF0000", Dark: "#FF0000"}
+	Away      = lip.AdaptiveColor{Light: "#D98E04", Dark: "#F5B642"}
+	Mention   = lip.AdaptiveColor{Light: "#D9480F", Dark: "#FF922B"}
+
+	Code           = lip.AdaptiveColor{Light: "#C2185B", Dark: "#FF8BA7"}
+	CodeBackground = lip.AdaptiveColor{Light: "#EEEEEE", Dark: "#262626"}
 
 	ErrorText = lip.NewStyle().Foreground(Error)
 
@@ -22,3 +27,21 @@ var (
 			BorderBottom(true).
 			BorderForeground(Subtle)
 )
+
+// ComposerHeight is how many rows the message composer grows to unless
+// configured otherwise.
+const ComposerHeight = 6
+
+// Themes are the accepted theme names. "auto" follows the terminal
+// background; "dark" and "light" force one side of the adaptive colors.
+var Themes = []string{"auto", "dark", "light"}
+
+// SetTheme switches every adaptive color to the given theme.
+func SetTheme(name string) {
+	switch name {
+	case "dark":
+		lip.SetHasDarkBackground(true)
+	case "light":
+		lip.SetHasDarkBackground(false)
+	}
+}
@@ -0,0 +1,107 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ProtocolVersion is the envelope version this build speaks. Clients send it
+// in ProtocolHeader when dialing and servers that understand envelopes echo
+// it back; without it both sides fall back to plain text frames.
+const (
+	ProtocolVersion = 1
+	ProtocolHeader  = "X-Chatt-Protocol"
+)
+
+type EnvelopeType string
+
+const (
+	TypeMessage EnvelopeType = "message"
+	TypeSystem  EnvelopeType = "system"
+	TypeJoin    EnvelopeType = "join"
+	TypeLeave   EnvelopeType = "leave"
+	TypeError   EnvelopeType = "error"
+	TypeTyping  EnvelopeType = "typing"
+	TypeHello   EnvelopeType = "hello"
+	TypeEdit    EnvelopeType = "edit"
+	TypeDelete  EnvelopeType = "delete"
+	TypeReact   EnvelopeType = "react"
+	TypeTopic   EnvelopeType = "topic"
+
+	// TypePresence is only sent over the control connection.
+	TypePresence EnvelopeType = "presence"
+)
+
+// Envelope is a single websocket frame. Payload depends on Type:
+//
+//   - Message for TypeMessage, TypeEdit and TypeDelete
+//   - Notice for TypeSystem and TypeError
+//   - Member for TypeJoin and TypeLeave
+//   - Reaction for TypeReact
+//   - Topic for TypeTopic
+//   - Typing for TypeTyping
+//   - Hello for TypeHello
+//   - Presence for TypePresence
+type Envelope struct {
+	Version      int             `json:"v"`
+	Type         EnvelopeType    `json:"type"`
+	ID           string          `json:"id,omitempty"`
+	Conversation string          `json:"conversation,omitempty"`
+	Payload      json.RawMessage `json:"payload,omitempty"`
+}
+
+type Notice struct {
+	Text      string    `json:"text"`
+	Timestamp time.Time `json:"timestamp"`
+	// Code tells errors apart, such as "too_long"; system notices leave it
+	// empty.
+	Code string `json:"code,omitempty"`
+}
+
+type Member struct {
+	User      string    `json:"user"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Typing is sent when a user starts or stops typing. The server fills in
+// User before relaying it to the rest of the conversation.
+type Typing struct {
+	User   string `json:"user"`
+	Typing bool   `json:"typing"`
+}
+
+// Hello is the first frame a server sends on a new connection. Topic is
+// only set for rooms that have one.
+type Hello struct {
+	Limits Limits `json:"limits"`
+	Topic  string `json:"topic,omitempty"`
+}
+
+// Topic sets the topic of a room when sent by a client. The server fills in
+// User and Timestamp before broadcasting it.
+type Topic struct {
+	Text      string    `json:"text"`
+	User      string    `json:"user,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Limits are what the server accepts from clients. Zero means no limit.
+type Limits struct {
+	MaxMessageLength int `json:"maxMessageLength"`
+}
+
+func NewEnvelope(t EnvelopeType, payload any) (Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{
+		Version: ProtocolVersion,
+		Type:    t,
+		Payload: data,
+	}, nil
+}
+
+func (e Envelope) Decode(v any) error {
+	return json.Unmarshal(e.Payload, v)
+}
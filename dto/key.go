@@ -0,0 +1,7 @@
+package dto
+
+// PublicKey is the X25519 key a user publishes with PUT /keys, and what
+// GET /keys/{name} answers with.
+type PublicKey struct {
+	Key []byte `json:"key"`
+}
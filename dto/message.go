@@ -0,0 +1,31 @@
+package dto
+
+import "time"
+
+type Message struct {
+	ID        string    `json:"id,omitempty"`
+	User      string    `json:"user"`
+	Data      string    `json:"data"`
+	Timestamp time.Time `json:"timestamp"`
+	Edited    bool      `json:"edited,omitempty"`
+	Deleted   bool      `json:"deleted,omitempty"`
+	// Action marks an IRC-style /me message, shown as "* user text".
+	Action bool `json:"action,omitempty"`
+	// Parent is the ID of the message this one replies to. Replies always
+	// point at the first message of their thread.
+	Parent string `json:"parent,omitempty"`
+	// Sealed means Data is an end-to-end encrypted box, only readable by
+	// the two users of a direct message.
+	Sealed bool `json:"sealed,omitempty"`
+
+	// Reactions maps an emoji to the users who reacted with it.
+	Reactions map[string][]string `json:"reactions,omitempty"`
+}
+
+// Reaction toggles Emoji on message ID when sent by a client. The server
+// answers with every reaction of the message in Reactions.
+type Reaction struct {
+	ID        string              `json:"id"`
+	Emoji     string              `json:"emoji,omitempty"`
+	Reactions map[string][]string `json:"reactions,omitempty"`
+}
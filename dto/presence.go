@@ -0,0 +1,16 @@
+package dto
+
+type PresenceStatus string
+
+const (
+	Online  PresenceStatus = "online"
+	Away    PresenceStatus = "away"
+	Offline PresenceStatus = "offline"
+)
+
+// Presence is pushed by the server whenever a user's status changes. Clients
+// send it with User left empty to report their own status.
+type Presence struct {
+	User   string         `json:"user"`
+	Status PresenceStatus `json:"status"`
+}
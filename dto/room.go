@@ -4,3 +4,6 @@ type Room struct {
 	Name string `json:"name"`
 	Lock bool   `json:"lock"`
 }
+
+// RoomPasswordHeader carries the password of a locked room on REST requests.
+const RoomPasswordHeader = "X-Room-Password"
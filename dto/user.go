@@ -0,0 +1,42 @@
+package dto
+
+// User is what GET /me answers with for a valid token.
+type User struct {
+	Name string `json:"name"`
+}
+
+// Credentials are posted as JSON to /login and /register.
+type Credentials struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// Session is the answer to a successful /login, /register or /refresh.
+// Servers without expiring tokens leave RefreshToken and ExpiresIn empty.
+type Session struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	// ExpiresIn is how many seconds Token lasts; zero is forever.
+	ExpiresIn int `json:"expiresIn,omitempty"`
+}
+
+// Refresh is posted as JSON to /refresh to get a new access token.
+type Refresh struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// AuthError is the body of a failed /login or /register.
+type AuthError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Codes of AuthError.
+const (
+	AuthInvalidName    = "invalid_name"
+	AuthNameTaken      = "name_taken"
+	AuthUnknownUser    = "unknown_user"
+	AuthWrongPassword  = "wrong_password"
+	AuthRateLimited    = "rate_limited"
+	AuthInvalidRefresh = "invalid_refresh"
+)
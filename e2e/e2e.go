@@ -0,0 +1,203 @@
+// Package e2e encrypts direct messages so that only the two people talking
+// can read them.
+//
+// Every user has an X25519 identity key kept on their machine; the public
+// half is published on the server. A message is sealed with NaCl box between
+// the sender's private key and the receiver's public key, which gives both
+// of them the same shared key: the sender can read its own messages back and
+// anyone else, the server included, can neither read nor alter them.
+package e2e
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// KeySize is the length of public and private keys.
+const KeySize = 32
+
+const nonceSize = 24
+
+// ErrOpen is returned for a box that was not sealed for us or was altered.
+var ErrOpen = errors.New("message can't be decrypted")
+
+// Key is a public key.
+type Key = [KeySize]byte
+
+// Identity is the key pair of the local user.
+type Identity struct {
+	Public  Key
+	private Key
+}
+
+// LoadIdentity reads the identity at path, creating one the first time. The
+// file holds the base64 private key and is only readable by its owner.
+func LoadIdentity(path string) (*Identity, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return createIdentity(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil || len(raw) != KeySize {
+		return nil, fmt.Errorf("%s: not a private key", path)
+	}
+	id := &Identity{}
+	copy(id.private[:], raw)
+	pub, err := curve25519.X25519(id.private[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	copy(id.Public[:], pub)
+	return id, nil
+}
+
+func createIdentity(path string) (*Identity, error) {
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	// O_EXCL keeps a second client starting at the same time from
+	// replacing the key the first one already published.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return LoadIdentity(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.WriteString(base64.StdEncoding.EncodeToString(priv[:]) + "\n"); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return &Identity{Public: *pub, private: *priv}, nil
+}
+
+// Shared returns the key id shares with the owner of peer. Both sides of a
+// conversation compute the same one.
+func (id *Identity) Shared(peer Key) *Key {
+	shared := new(Key)
+	box.Precompute(shared, &peer, &id.private)
+	return shared
+}
+
+// Header names who a sealed text is from and for and, for an edit, the
+// message it replaces. It is sealed along with the text, so a box the server
+// bounces back to its sender or hands to someone else does not open. A new
+// message has no ID yet when it is sealed, so a box the server posts again
+// as another message still opens; Replays is what catches that.
+type Header struct {
+	From string
+	To   string
+	ID   string
+}
+
+// Seal encrypts text with a shared key. The result is the base64 of a random
+// nonce followed by the box of h and text.
+func Seal(shared *Key, h Header, text string) (string, error) {
+	var nonce [nonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return "", err
+	}
+	plain := appendField(nil, h.From)
+	plain = appendField(plain, h.To)
+	plain = appendField(plain, h.ID)
+	plain = append(plain, text...)
+
+	sealed := box.SealAfterPrecomputation(nonce[:], plain, &nonce, shared)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts what Seal returned, checking that it was sealed with h. A
+// box sealed without an ID opens under any.
+func Open(shared *Key, h Header, sealed string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < nonceSize+box.Overhead {
+		return "", ErrOpen
+	}
+	var nonce [nonceSize]byte
+	copy(nonce[:], raw)
+	plain, ok := box.OpenAfterPrecomputation(nil, raw[nonceSize:], &nonce, shared)
+	if !ok {
+		return "", ErrOpen
+	}
+
+	var got Header
+	for _, field := range []*string{&got.From, &got.To, &got.ID} {
+		if *field, plain, ok = readField(plain); !ok {
+			return "", ErrOpen
+		}
+	}
+	if got.From != h.From || got.To != h.To || (got.ID != "" && got.ID != h.ID) {
+		return "", ErrOpen
+	}
+	return string(plain), nil
+}
+
+// appendField appends s to b, prefixed with its length.
+func appendField(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// readField reads a field written by appendField off the front of b.
+func readField(b []byte) (string, []byte, bool) {
+	n, size := binary.Uvarint(b)
+	if size <= 0 || n > uint64(len(b)-size) {
+		return "", nil, false
+	}
+	b = b[size:]
+	return string(b[:n]), b[n:], true
+}
+
+// SafetyNumber is a fingerprint of the keys of a conversation, the same on
+// both sides. When two users read out the same number, nobody is in the
+// middle swapping keys.
+func SafetyNumber(a Key, b Key) string {
+	if string(a[:]) > string(b[:]) {
+		a, b = b, a
+	}
+	sum := sha512.Sum512(append(a[:], b[:]...))
+
+	// Twelve groups of five digits, each from five bytes of the hash.
+	groups := make([]string, 12)
+	for i := range groups {
+		var n uint64
+		for _, v := range sum[i*5 : i*5+5] {
+			n = n<<8 | uint64(v)
+		}
+		groups[i] = fmt.Sprintf("%05d", n%100000)
+	}
+	return strings.Join(groups, " ")
+}
+
+// ParseKey checks that b is a public key.
+func ParseKey(b []byte) (Key, error) {
+	var key Key
+	if len(b) != KeySize {
+		return key, fmt.Errorf("public keys are %d bytes, got %d", KeySize, len(b))
+	}
+	copy(key[:], b)
+	return key, nil
+}
@@ -0,0 +1,152 @@
+package e2e
+
+import (
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func identity(t *testing.T, name string) *Identity {
+	t.Helper()
+	id, err := LoadIdentity(filepath.Join(t.TempDir(), name+".key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestLoadIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identity.key")
+	first, err := LoadIdentity(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadIdentity(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Public != again.Public {
+		t.Error("loading an identity again gave another key")
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	alice, bob, eve := identity(t, "alice"), identity(t, "bob"), identity(t, "eve")
+	aliceShared, bobShared := alice.Shared(bob.Public), bob.Shared(alice.Public)
+	sent := Header{From: "alice", To: "bob"}
+
+	tests := []struct {
+		name   string
+		shared *Key
+		h      Header
+		box    func(string) string
+		want   string
+		err    error
+	}{
+		{name: "opened by the receiver", shared: bobShared, h: sent, want: "hello"},
+		{name: "read back by the sender", shared: aliceShared, h: sent, want: "hello"},
+		{name: "any ID when sealed without one", shared: bobShared, h: Header{From: "alice", To: "bob", ID: "7"}, want: "hello"},
+		{name: "someone else's key", shared: eve.Shared(alice.Public), h: sent, err: ErrOpen},
+		{name: "bounced back to the sender", shared: aliceShared, h: Header{From: "bob", To: "alice"}, err: ErrOpen},
+		{name: "handed to someone else", shared: bobShared, h: Header{From: "alice", To: "carol"}, err: ErrOpen},
+		{
+			name:   "altered",
+			shared: bobShared,
+			h:      sent,
+			box: func(box string) string {
+				raw, _ := base64.StdEncoding.DecodeString(box)
+				raw[len(raw)-1] ^= 1
+				return base64.StdEncoding.EncodeToString(raw)
+			},
+			err: ErrOpen,
+		},
+		{
+			name:   "cut short",
+			shared: bobShared,
+			h:      sent,
+			box: func(box string) string {
+				raw, _ := base64.StdEncoding.DecodeString(box)
+				return base64.StdEncoding.EncodeToString(raw[:nonceSize])
+			},
+			err: ErrOpen,
+		},
+		{name: "not base64", shared: bobShared, h: sent, box: func(string) string { return "not a box!" }, err: ErrOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box, err := Seal(aliceShared, sent, "hello")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(box, "hello") {
+				t.Fatal("the box holds the plaintext")
+			}
+			if tt.box != nil {
+				box = tt.box(box)
+			}
+
+			got, err := Open(tt.shared, tt.h, box)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSealID(t *testing.T) {
+	alice, bob := identity(t, "alice"), identity(t, "bob")
+	shared := alice.Shared(bob.Public)
+
+	box, err := Seal(shared, Header{From: "alice", To: "bob", ID: "7"}, "edited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(shared, Header{From: "alice", To: "bob", ID: "7"}, box); err != nil {
+		t.Errorf("opening under its own ID: %v", err)
+	}
+	if _, err := Open(shared, Header{From: "alice", To: "bob", ID: "8"}, box); !errors.Is(err, ErrOpen) {
+		t.Errorf("opening under another ID: got %v, want %v", err, ErrOpen)
+	}
+}
+
+func TestSafetyNumber(t *testing.T) {
+	alice, bob, eve := identity(t, "alice"), identity(t, "bob"), identity(t, "eve")
+
+	ab, ba := SafetyNumber(alice.Public, bob.Public), SafetyNumber(bob.Public, alice.Public)
+	if ab != ba {
+		t.Errorf("the two sides see %q and %q", ab, ba)
+	}
+	if ae := SafetyNumber(alice.Public, eve.Public); ae == ab {
+		t.Error("another key gave the same safety number")
+	}
+	if groups := strings.Fields(ab); len(groups) != 12 {
+		t.Errorf("got %d groups, want 12", len(groups))
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+		ok   bool
+	}{
+		{name: "key", b: make([]byte, KeySize), ok: true},
+		{name: "short", b: make([]byte, KeySize-1)},
+		{name: "long", b: make([]byte, KeySize+1)},
+		{name: "empty", b: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseKey(tt.b); (err == nil) != tt.ok {
+				t.Errorf("got error %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
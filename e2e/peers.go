@@ -0,0 +1,101 @@
+package e2e
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Peers remembers the key first seen for every user, so that a key swapped
+// later, by the user or by the server, does not go unnoticed.
+type Peers struct {
+	path string
+
+	mu   sync.Mutex
+	keys map[string][]byte
+}
+
+// LoadPeers reads the keys remembered in the JSON file at path.
+func LoadPeers(path string) (*Peers, error) {
+	p := &Peers{path: path, keys: map[string][]byte{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &p.keys); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Check compares key with the one remembered for user and reports whether
+// it changed. The first key seen for a user is remembered.
+func (p *Peers) Check(user string, key Key) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	known, ok := p.keys[user]
+	if ok {
+		return string(known) != string(key[:]), nil
+	}
+	p.keys[user] = key[:]
+	return false, p.write()
+}
+
+// Known reports whether a key is remembered for user.
+func (p *Peers) Known(user string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, ok := p.keys[user]
+	return ok
+}
+
+// Trust remembers key for user in place of the previous one.
+func (p *Peers) Trust(user string, key Key) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keys[user] = key[:]
+	return p.write()
+}
+
+// Forget drops the key remembered for user, once they no longer have one.
+func (p *Peers) Forget(user string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.keys, user)
+	return p.write()
+}
+
+func (p *Peers) write() error {
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(p.keys, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(p.path), ".peers-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p.path)
+}
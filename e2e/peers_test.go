@@ -0,0 +1,93 @@
+package e2e
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPeers(t *testing.T) {
+	first, second := Key{1}, Key{2}
+
+	tests := []struct {
+		name  string
+		steps func(p *Peers) error
+		key   Key
+		known bool
+		want  bool
+	}{
+		{
+			name:  "first key is remembered",
+			steps: func(p *Peers) error { _, err := p.Check("bob", first); return err },
+			key:   first,
+			known: true,
+			want:  false,
+		},
+		{
+			name:  "another key is a change",
+			steps: func(p *Peers) error { _, err := p.Check("bob", first); return err },
+			key:   second,
+			known: true,
+			want:  true,
+		},
+		{
+			name: "trusted key replaces the old one",
+			steps: func(p *Peers) error {
+				if _, err := p.Check("bob", first); err != nil {
+					return err
+				}
+				return p.Trust("bob", second)
+			},
+			key:   second,
+			known: true,
+			want:  false,
+		},
+		{
+			name: "forgotten key is not a change",
+			steps: func(p *Peers) error {
+				if _, err := p.Check("bob", first); err != nil {
+					return err
+				}
+				return p.Forget("bob")
+			},
+			key:   second,
+			known: false,
+			want:  false,
+		},
+		{
+			name:  "keys are per user",
+			steps: func(p *Peers) error { _, err := p.Check("carol", first); return err },
+			key:   second,
+			known: false,
+			want:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "peers.json")
+			p, err := LoadPeers(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.steps(p); err != nil {
+				t.Fatal(err)
+			}
+			if known := p.Known("bob"); known != tt.known {
+				t.Errorf("Known = %v, want %v", known, tt.known)
+			}
+
+			// What was remembered has to survive a restart.
+			p, err = LoadPeers(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			changed, err := p.Check("bob", tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != tt.want {
+				t.Errorf("Check = %v, want %v", changed, tt.want)
+			}
+		})
+	}
+}
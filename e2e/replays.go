@@ -0,0 +1,39 @@
+package e2e
+
+import (
+	"encoding/base64"
+	"sync"
+)
+
+// Replays remembers which message first carried each box, by the box's
+// random nonce. The server can't alter a box, but it can post one again as
+// a new message; the copy carries the same nonce under another ID.
+type Replays struct {
+	mu   sync.Mutex
+	seen map[string]string
+}
+
+// NewReplays returns an empty Replays.
+func NewReplays() *Replays {
+	return &Replays{seen: map[string]string{}}
+}
+
+// Replayed remembers that the message id carried sealed and reports whether
+// another message carried it before.
+func (r *Replays) Replayed(sealed string, id string) bool {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < nonceSize {
+		return false
+	}
+	nonce := string(raw[:nonceSize])
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	first, ok := r.seen[nonce]
+	if !ok {
+		r.seen[nonce] = id
+		return false
+	}
+	return first != id
+}
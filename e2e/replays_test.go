@@ -0,0 +1,39 @@
+package e2e
+
+import "testing"
+
+func TestReplays(t *testing.T) {
+	alice, bob := identity(t, "alice"), identity(t, "bob")
+	shared := alice.Shared(bob.Public)
+	h := Header{From: "alice", To: "bob"}
+
+	first, err := Seal(shared, h, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Seal(shared, h, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		box  string
+		id   string
+		want bool
+	}{
+		{name: "first delivery", box: first, id: "1", want: false},
+		{name: "same message loaded again", box: first, id: "1", want: false},
+		{name: "another box with the same text", box: second, id: "2", want: false},
+		{name: "box delivered again under a new ID", box: first, id: "3", want: true},
+		{name: "not a box", box: "!", id: "4", want: false},
+	}
+
+	// The cases run in order against the same Replays.
+	r := NewReplays()
+	for _, tt := range tests {
+		if got := r.Replayed(tt.box, tt.id); got != tt.want {
+			t.Errorf("%s: Replayed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
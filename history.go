@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/store"
+)
+
+// history browses the local message store without connecting to a server.
+func history(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	path := fs.String("config", "", "config file (default $XDG_CONFIG_HOME/chatt/config.toml)")
+	server := fs.String("server", "", "only look at conversations on this server")
+	user := fs.String("user", "", "only look at conversations of this user")
+	n := fs.Int("n", 50, "how many of the latest messages to print, 0 for all")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: chatt history [flags] [conversation]")
+		fmt.Fprintln(fs.Output(), "\nLists the stored conversations, or prints one of them. A conversation is")
+		fmt.Fprintln(fs.Output(), "a room or user name, optionally prefixed with # or @.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	cfg, err := loadConfig(*path)
+	if err != nil {
+		fatal(err)
+	}
+	accounts, err := store.Accounts()
+	if err != nil {
+		fatal(err)
+	}
+
+	type found struct {
+		account store.Account
+		store   *store.Store
+		key     string
+	}
+	matches := []found{}
+	for _, a := range accounts {
+		if *server != "" && a.Server != store.ServerName(*server) {
+			continue
+		}
+		if *user != "" && a.User != *user {
+			continue
+		}
+
+		// Load never rewrites the files, so browsing leaves them as they are.
+		s, err := store.Open(a.Server, a.User, cfg.StoreLimit)
+		if err != nil {
+			fatal(err)
+		}
+		keys, err := s.Conversations()
+		if err != nil {
+			fatal(err)
+		}
+		for _, key := range keys {
+			if fs.NArg() == 0 || matchConversation(key, fs.Arg(0)) {
+				matches = append(matches, found{a, s, key})
+			}
+		}
+	}
+
+	if fs.NArg() == 0 {
+		if len(matches) == 0 {
+			fmt.Println("no stored conversations")
+		}
+		for _, v := range matches {
+			msgs, err := v.store.Load(v.key)
+			if err != nil {
+				fatal(err)
+			}
+			last := "never"
+			if len(msgs) > 0 {
+				last = msgs[len(msgs)-1].Timestamp.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Printf("%-24s %-16s %-20s %5d messages, last %s\n", v.account.Server, v.account.User, v.key, len(msgs), last)
+		}
+		return
+	}
+
+	switch len(matches) {
+	case 0:
+		fatal(fmt.Errorf("no stored conversation %q", fs.Arg(0)))
+	case 1:
+	default:
+		fmt.Fprintf(os.Stderr, "%q matches several conversations, pick one with -server and -user:\n", fs.Arg(0))
+		for _, v := range matches {
+			fmt.Fprintf(os.Stderr, "  -server %s -user %s %s\n", v.account.Server, v.account.User, v.key)
+		}
+		os.Exit(1)
+	}
+
+	msgs, err := matches[0].store.Load(matches[0].key)
+	if err != nil {
+		fatal(err)
+	}
+	if *n > 0 && len(msgs) > *n {
+		msgs = msgs[len(msgs)-*n:]
+	}
+	for _, v := range msgs {
+		fmt.Println(formatStored(v))
+	}
+}
+
+// matchConversation reports whether the conversation key was asked for by
+// name, which may leave out the # or @.
+func matchConversation(key string, name string) bool {
+	return key == name || key[1:] == name
+}
+
+func formatStored(v dto.Message) string {
+	text := v.Data
+	switch {
+	case v.Deleted:
+		text = "(deleted)"
+	case v.Action:
+		text = "* " + v.User + " " + text
+	}
+	if v.Edited && !v.Deleted {
+		text += " (edited)"
+	}
+
+	prefix := v.Timestamp.Local().Format("2006-01-02 15:04") + "  "
+	if !v.Action || v.Deleted {
+		prefix += v.User + ": "
+	}
+	indent := strings.Repeat(" ", len(prefix))
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+indent)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "chatt:", err)
+	os.Exit(1)
+}
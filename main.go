@@ -1,16 +1,25 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"io/fs"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	lip "github.com/charmbracelet/lipgloss"
 	"github.com/onfirebyte/chatt/common"
+	"github.com/onfirebyte/chatt/config"
+	"github.com/onfirebyte/chatt/design"
+	"github.com/onfirebyte/chatt/dto"
 	"github.com/onfirebyte/chatt/model"
+	"github.com/onfirebyte/chatt/request"
+	"github.com/onfirebyte/chatt/server"
 	"github.com/onfirebyte/chatt/signal"
 )
 
@@ -42,15 +51,26 @@ var (
 
 type mainModel struct {
 	state           SessionState
+	client          *request.Client
 	createUserModel model.CreateUser
 	homeModel       model.Home
+
+	// relogin is shown over Home while the session is expired.
+	relogin *model.Relogin
+	width   int
+
+	user    string
+	options model.Options
 }
 
-func newModel() mainModel {
+func newModel(client *request.Client, user string, options model.Options) mainModel {
 	m := mainModel{
 		state:           createUserState,
-		createUserModel: model.NewCreateUserModel(),
-		homeModel:       model.NewHomeModel(),
+		client:          client,
+		createUserModel: model.NewCreateUserModel(client, user),
+		homeModel:       model.NewHomeModel(client, options),
+		user:            user,
+		options:         options,
 	}
 
 	return m
@@ -63,6 +83,7 @@ func (m mainModel) Init() tea.Cmd {
 		common.Spinner.Tick,
 		m.createUserModel.Init(),
 		m.homeModel.Init(),
+		model.WaitExpired(m.client),
 	)
 }
 
@@ -77,11 +98,14 @@ func (m mainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.homeModel.Update(tea.QuitMsg{})
 			return m, tea.Quit
 		}
-		switch m.state {
-		case createUserState:
+		switch {
+		case m.relogin != nil:
+			*m.relogin, cmd = m.relogin.Update(msg)
+			cmds = append(cmds, cmd)
+		case m.state == createUserState:
 			m.createUserModel, cmd = m.createUserModel.Update(msg)
 			cmds = append(cmds, cmd)
-		case mainMenuState:
+		case m.state == mainMenuState:
 			m.homeModel, cmd = m.homeModel.Update(msg)
 			cmds = append(cmds, cmd)
 		}
@@ -91,17 +115,46 @@ func (m mainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		common.Spinner = s
 		cmds = append(cmds, cmd)
 
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+
 	case signal.UserInfo:
-		common.UserName = msg.Name
-		common.Token = msg.Token
+		m.client.SetSession(msg.Name, dto.Session{Token: msg.Token, RefreshToken: msg.RefreshToken})
 
 		m.state = mainMenuState
 		m.homeModel, cmd = m.homeModel.Update(signal.Refetch("all"))
 		cmds = append(cmds, cmd)
 
+	case signal.SessionExpired:
+		cmds = append(cmds, model.WaitExpired(m.client))
+		// Before logging in, requests just wait for the login screen.
+		if m.state == mainMenuState && m.relogin == nil {
+			relogin := model.NewReloginModel(m.client)
+			m.relogin = &relogin
+			cmds = append(cmds, relogin.Init())
+		}
+
+	case signal.Reauthenticated:
+		m.relogin = nil
+
+	case signal.Logout:
+		// Close every connection, then start over from the login screen.
+		m.homeModel.Update(tea.QuitMsg{})
+		m.client.SetCredentials("", "")
+		m.relogin = nil
+
+		m.state = createUserState
+		m.createUserModel = model.NewCreateUserModel(m.client, m.user)
+		m.homeModel = model.NewHomeModel(m.client, m.options)
+		return m, tea.Batch(m.createUserModel.Init(), m.homeModel.Init())
 	}
 
 	if _, ok := msg.(tea.KeyMsg); !ok {
+		if m.relogin != nil {
+			*m.relogin, cmd = m.relogin.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+
 		m.createUserModel, cmd = m.createUserModel.Update(msg)
 		cmds = append(cmds, cmd)
 
@@ -117,43 +170,185 @@ func (m mainModel) View() string {
 	case createUserState:
 		return m.createUserModel.View()
 	case mainMenuState:
+		if m.relogin != nil {
+			return overlay(m.homeModel.View(), m.relogin.View(), m.width)
+		}
 		return m.homeModel.View()
 	}
 	return ""
 }
 
+// overlay draws box centered over the middle rows of background.
+func overlay(background string, box string, width int) string {
+	rows := strings.Split(background, "\n")
+	lines := strings.Split(box, "\n")
+	top := max((len(rows)-len(lines))/2, 0)
+	for i, line := range lines {
+		line = lip.PlaceHorizontal(width, lip.Center, line)
+		if top+i < len(rows) {
+			rows[top+i] = line
+		} else {
+			rows = append(rows, line)
+		}
+	}
+	return strings.Join(rows, "\n")
+}
+
 func (m mainModel) currentFocusedModel() string {
 	return "spinner"
 }
 
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	cert := fs.String("tls-cert", "", "serve TLS with this certificate")
+	key := fs.String("tls-key", "", "key of the TLS certificate")
+	ttl := fs.Duration("token-ttl", server.DefaultTokenTTL, "how long access tokens last, 0 for forever")
+	fs.Parse(args)
+
+	s := server.New()
+	s.TokenTTL = *ttl
+	if *cert != "" || *key != "" {
+		log.Fatal(s.ListenAndServeTLS(*addr, *cert, *key))
+	}
+	log.Fatal(s.ListenAndServe(*addr))
+}
+
+// configure builds the client settings from the config file, the
+// environment and the command line, in that order of precedence.
+func configure(args []string) (config.Config, error) {
+	flags := flag.NewFlagSet("chatt", flag.ExitOnError)
+	path := flags.String("config", "", "config file (default $XDG_CONFIG_HOME/chatt/config.toml)")
+	server := flags.String("server", "", "server URL, such as localhost:8080")
+	user := flags.String("user", "", "user name to log in with")
+	logFile := flags.String("log-file", "", "write debug logs to this file")
+	theme := flags.String("theme", "", "color theme: "+strings.Join(design.Themes, ", "))
+	caFile := flags.String("ca-file", "", "PEM bundle of extra CAs to trust")
+	certFile := flags.String("cert-file", "", "client certificate for mutual TLS")
+	keyFile := flags.String("key-file", "", "key of the client certificate")
+	insecure := flags.Bool("insecure-skip-verify", false, "accept any server certificate (development only)")
+	proxy := flags.String("proxy", "", "http:// or socks5:// proxy URL (default $HTTPS_PROXY)")
+	e2e := flags.Bool("e2e", false, "encrypt direct messages end to end")
+	composerHeight := flags.Int("composer-height", 0, fmt.Sprintf("rows the message composer grows to (default %d)", design.ComposerHeight))
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: chatt [flags] [server]")
+		fmt.Fprintln(flags.Output(), "       chatt serve [flags]")
+		fmt.Fprintln(flags.Output(), "       chatt history [flags] [conversation]")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		return config.Default(), fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args()[1:], " "))
+	}
+
+	cfg, err := loadConfig(*path)
+	if err != nil {
+		return cfg, err
+	}
+
+	// The server can still be given positionally, as before the flags.
+	if flags.NArg() == 1 {
+		cfg.Server = flags.Arg(0)
+	}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "server":
+			cfg.Server = *server
+		case "user":
+			cfg.User = *user
+		case "log-file":
+			cfg.LogFile = *logFile
+		case "theme":
+			cfg.Theme = *theme
+		case "ca-file":
+			cfg.CAFile = *caFile
+		case "cert-file":
+			cfg.CertFile = *certFile
+		case "key-file":
+			cfg.KeyFile = *keyFile
+		case "insecure-skip-verify":
+			cfg.InsecureSkipVerify = *insecure
+		case "proxy":
+			cfg.Proxy = *proxy
+		case "e2e":
+			cfg.E2E = *e2e
+		case "composer-height":
+			cfg.ComposerHeight = *composerHeight
+		}
+	})
+
+	return cfg, cfg.Validate()
+}
+
+// loadConfig reads the config file at path, or the default one when path is
+// empty, and applies the environment on top.
+func loadConfig(path string) (config.Config, error) {
+	cfg := config.Default()
+	file := path
+	if file == "" {
+		var err error
+		if file, err = config.Path(); err != nil {
+			return cfg, err
+		}
+	}
+	// Only a file named with --config has to exist.
+	if err := cfg.ReadFile(file); err != nil && (path != "" || !errors.Is(err, fs.ErrNotExist)) {
+		return cfg, err
+	}
+	return cfg, cfg.ApplyEnv()
+}
+
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Please provide a URL")
-		os.Exit(1)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			serve(os.Args[2:])
+			return
+		case "history":
+			history(os.Args[2:])
+			return
+		}
 	}
-	rawURL := os.Args[1]
-	if rawURL == "" {
-		log.Fatal("Please provide a URL")
+
+	cfg, err := configure(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "chatt:", err)
+		os.Exit(2)
 	}
 
-	if rawURL[:4] != "http" {
-		rawURL = "http://" + rawURL
+	client, err := request.NewClientWithOptions(cfg.Server, request.Options{
+		CAFile:             cfg.CAFile,
+		CertFile:           cfg.CertFile,
+		KeyFile:            cfg.KeyFile,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		Proxy:              cfg.Proxy,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "chatt:", err)
+		os.Exit(2)
 	}
 
-	common.URL = rawURL
+	options := model.DefaultOptions()
+	options.Markdown = cfg.Markdown
+	options.ComposerHeight = cfg.ComposerHeight
+	options.StoreLimit = cfg.StoreLimit
+	options.E2E = cfg.E2E
+	options.StoreEncrypted = cfg.StoreEncrypted
+	design.SetTheme(cfg.Theme)
 
-	if os.Getenv("DEBUG") != "" {
-		f, err := tea.LogToFile("debug.log", "debug")
+	if cfg.LogFile != "" {
+		f, err := tea.LogToFile(cfg.LogFile, "debug")
 		if err != nil {
-			fmt.Println("fatal:", err)
+			fmt.Fprintln(os.Stderr, "chatt:", err)
 			os.Exit(1)
 		}
 		defer f.Close()
 	} else {
-		log.SetOutput(ioutil.Discard)
+		log.SetOutput(io.Discard)
 	}
 
-	p := tea.NewProgram(newModel())
+	p := tea.NewProgram(newModel(client, cfg.User, options))
 
 	if _, err := p.Run(); err != nil {
 		log.Fatal(err)
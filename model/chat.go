@@ -1,285 +1,947 @@
 package model
 
 import (
-	"encoding/json"
-	"log"
-	"net/http"
-	"net/url"
+	"fmt"
+	"regexp"
 	"strings"
-	"time"
 
-	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	lip "github.com/charmbracelet/lipgloss"
 	"github.com/gorilla/websocket"
 	"github.com/onfirebyte/chatt/common"
 	"github.com/onfirebyte/chatt/design"
+	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/e2e"
+	"github.com/onfirebyte/chatt/request"
 	"github.com/onfirebyte/chatt/signal"
+	"github.com/onfirebyte/chatt/store"
 )
 
+// Messages produced by a conversation carry its key and, where it matters,
+// the connection they came from so stale reads can be told apart.
 type (
 	chatResult signal.Result[[]string]
-	chatError  error
-	chatConn   *websocket.Conn
+	chatError  struct {
+		key        string
+		connection *request.Conn
+		// dial is set on failed dials, whose connection is nil.
+		dial uint64
+		err  error
+	}
+	chatConn struct {
+		key        string
+		dial       uint64
+		connection *request.Conn
+		// peerKey is the key of the other user of an encrypted direct
+		// message, nil when there is none.
+		peerKey []byte
+	}
+	chatReceived struct {
+		key        string
+		connection *request.Conn
+		message    chatMessage
+	}
+	chatHistory struct {
+		key        string
+		connection *request.Conn
+		messages   []chatMessage
+		err        error
+	}
 )
 
+// chatMessage is one entry of the timeline. Kind tells user messages apart
+// from system events, which reuse User for the member they are about and
+// Data for their text.
 type chatMessage struct {
-	User      string    `json:"user"`
-	Message   string    `json:"data"`
-	Timestamp time.Time `json:"timestamp"`
+	dto.Message
+	Kind dto.EnvelopeType
+	// Unverified marks plaintext that turned up in an encrypted
+	// conversation.
+	Unverified bool
 }
 
-type Chat struct {
-	title   string
-	width   int
-	height  int
-	focus   bool
-	loading bool
-
-	offset int
-
-	connection *websocket.Conn
+// historyPageSize is how many messages are fetched per history request.
+const historyPageSize = 50
 
-	data  []chatMessage
-	error error
+type Chat struct {
+	title  string
+	width  int
+	height int
+	focus  bool
+
+	client  *request.Client
+	options Options
+	// store is nil until we log in, and when the store is turned off.
+	store *store.Store
+	// identity, peers and replays are nil until we log in, and when
+	// encryption is turned off.
+	identity *e2e.Identity
+	peers    *e2e.Peers
+	replays  *e2e.Replays
+
+	// raw shows messages as typed even when markdown is enabled.
+	raw bool
+	// picker is open while choosing a reaction.
+	picker *reactionPicker
+
+	// users and rooms are the lists shown in the other tabs, used for
+	// completion.
+	users      []string
+	rooms      []string
+	completion completionState
+
+	conversations []*conversation
+	active        int
+	// lobby holds the output of commands run with no conversation open.
+	lobby *conversation
+	// dials numbers every dial, across conversations, since a closed
+	// conversation can be reopened while its old dial is still running.
+	dials uint64
+
+	composer textarea.Model
+}
 
-	textInput textinput.Model
+func NewChatModel(name string, client *request.Client, options Options) Chat {
+	return Chat{
+		title:    name,
+		client:   client,
+		options:  options,
+		composer: newComposer(),
+		lobby:    &conversation{},
+	}
 }
 
-func NewChatModel(name string) Chat {
-	ti := textinput.New()
-	ti.Placeholder = "Type a message..."
-	ti.Blur()
-	ti.CharLimit = 128
+// ConnectWS dials data for the conversation with key. dial tells this
+// attempt apart from earlier ones still in flight.
+func ConnectWS(client *request.Client, key string, dial uint64, data signal.Connect) tea.Cmd {
+	return func() tea.Msg {
+		c, err := client.Dial(data)
+		if err != nil {
+			return chatError{key: key, dial: dial, err: err}
+		}
 
-	return Chat{
-		title:     name,
-		textInput: ti,
-		loading:   false,
+		return chatConn{key: key, dial: dial, connection: c}
 	}
 }
 
-func ConnectWS(oldConn *websocket.Conn, data signal.Connect) tea.Cmd {
+func ReadMessage(key string, c *request.Conn) tea.Cmd {
 	return func() tea.Msg {
-		u, err := url.Parse(common.URL)
-		u.Scheme = "ws"
-		u.Path = "/ws"
+		for {
+			env, err := c.Receive()
+			if err != nil {
+				c.Close()
+				return chatError{key: key, connection: c, err: err}
+			}
 
-		if err != nil {
-			return func() tea.Msg {
-				return chatError(err)
+			if env.Type == dto.TypeHello {
+				var hello dto.Hello
+				if err := env.Decode(&hello); err == nil {
+					return chatHello{key: key, connection: c, hello: hello}
+				}
+				continue
+			}
+			if env.Type == dto.TypeTyping {
+				var typing dto.Typing
+				if err := env.Decode(&typing); err == nil {
+					return typingReceived{key: key, connection: c, typing: typing}
+				}
+				continue
 			}
-		}
 
-		q := u.Query()
-		q.Set("senderUserName", common.UserName)
-		if data.IsRoom {
-			roomName := data.Value
-			if data.Password != "" {
-				roomName = roomName + ":" + data.Password
+			data, ok, err := decodeEnvelope(env)
+			if err != nil {
+				return chatError{key: key, connection: c, err: err}
+			}
+			if ok {
+				return chatReceived{key: key, connection: c, message: data}
 			}
-			q.Set("roomName", url.QueryEscape(roomName))
-		} else {
-			q.Set("recvUserName", url.QueryEscape(data.Value))
 		}
+	}
+}
 
-		u.RawQuery = q.Encode()
-
-		header := http.Header{}
-		header.Set("Authorization", "Bearer "+common.Token)
+// decodeEnvelope turns an envelope into a timeline entry. Types this build
+// doesn't know about are skipped.
+func decodeEnvelope(env dto.Envelope) (chatMessage, bool, error) {
+	res := chatMessage{Kind: env.Type}
+	switch env.Type {
+	case dto.TypeMessage, dto.TypeEdit, dto.TypeDelete:
+		err := env.Decode(&res.Message)
+		return res, true, err
+	case dto.TypeReact:
+		var reaction dto.Reaction
+		err := env.Decode(&reaction)
+		res.ID = reaction.ID
+		res.Reactions = reaction.Reactions
+		return res, true, err
+	case dto.TypeSystem, dto.TypeError:
+		var notice dto.Notice
+		err := env.Decode(&notice)
+		res.Data = notice.Text
+		res.Timestamp = notice.Timestamp
+		return res, true, err
+	case dto.TypeTopic:
+		var topic dto.Topic
+		err := env.Decode(&topic)
+		res.User = topic.User
+		res.Data = topic.Text
+		res.Timestamp = topic.Timestamp
+		return res, true, err
+	case dto.TypeJoin, dto.TypeLeave:
+		var member dto.Member
+		err := env.Decode(&member)
+		res.User = member.User
+		res.Timestamp = member.Timestamp
+		return res, true, err
+	}
+	return res, false, nil
+}
 
-		log.Printf("connecting to %s", u.String())
-		c, _, err := websocket.DefaultDialer.Dial(u.String(), header)
+// FetchHistory loads the page of messages sent before the given one; a zero
+// message loads the latest page. The page is dropped if connection was
+// replaced meanwhile.
+func FetchHistory(client *request.Client, key string, connection *request.Conn, target signal.Connect, before dto.Message) tea.Cmd {
+	return func() tea.Msg {
+		res, err := client.GetHistory(target, before, historyPageSize)
 		if err != nil {
-			log.Println("dial error:", err)
-			return func() tea.Msg {
-				return chatError(err)
-			}
+			return chatHistory{key: key, connection: connection, err: err}
 		}
 
-		return chatConn(c)
+		messages := make([]chatMessage, len(res))
+		for i, v := range res {
+			messages[i] = chatMessage{Message: v, Kind: dto.TypeMessage}
+		}
+		return chatHistory{key: key, connection: connection, messages: messages}
 	}
 }
 
-func (m *Chat) ReadMessage() tea.Msg {
-	c := m.connection
-	_, message, err := m.connection.ReadMessage()
+func (m *Chat) Init() tea.Cmd {
+	return nil
+}
 
-	// It is possible that connection on model is changed while waiting
-	if c != m.connection {
+// current returns the conversation shown in the pane, or nil if none is open.
+func (m *Chat) current() *conversation {
+	if m.active < 0 || m.active >= len(m.conversations) {
 		return nil
 	}
+	return m.conversations[m.active]
+}
 
-	if err != nil {
-		c.Close()
-		if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
-			return nil
+func (m *Chat) find(key string) *conversation {
+	for _, c := range m.conversations {
+		if c.key == key {
+			return c
 		}
-		return chatError(err)
 	}
-	var data chatMessage
-	err = json.Unmarshal(message, &data)
-	if err != nil {
-		return chatError(err)
+	return nil
+}
+
+// switchTo makes the conversation at idx the visible one, keeping the draft
+// of the one we leave.
+func (m *Chat) switchTo(idx int) tea.Cmd {
+	if len(m.conversations) == 0 {
+		m.active = 0
+		m.composer.SetValue("")
+		return nil
+	}
+
+	var cmd tea.Cmd
+	if cur := m.current(); cur != nil {
+		// The picker reacts to a message of the conversation we leave.
+		if cur != m.conversations[(idx+len(m.conversations))%len(m.conversations)] {
+			m.picker = nil
+		}
+		if cur.editing != "" {
+			m.cancelSelection(cur)
+		}
+		m.stashDraft(cur)
+		cmd = cur.typing.stop(cur)
 	}
 
-	return data
+	m.active = (idx + len(m.conversations)) % len(m.conversations)
+	next := m.conversations[m.active]
+	next.unread = 0
+	next.mentions = 0
+	m.applyLimits(next)
+	m.restoreDraft(next)
+	return cmd
 }
 
-func (m *Chat) Init() tea.Cmd {
-	return nil
+func (m *Chat) closeCurrent() {
+	cur := m.current()
+	if cur == nil {
+		return
+	}
+	// The connection is closed right after, so a failed typing-stop
+	// doesn't matter.
+	cur.typing.stop(cur)
+	cur.close()
+	m.picker = nil
+
+	m.conversations = append(m.conversations[:m.active], m.conversations[m.active+1:]...)
+	if m.active >= len(m.conversations) {
+		m.active = len(m.conversations) - 1
+	}
+	if next := m.current(); next != nil {
+		next.unread = 0
+		next.mentions = 0
+		m.applyLimits(next)
+		m.restoreDraft(next)
+	} else {
+		m.active = 0
+		m.composer.SetValue("")
+		m.resizeComposer()
+	}
 }
 
 func (m *Chat) Update(msg tea.Msg) (*Chat, tea.Cmd) {
 	var cmds []tea.Cmd
 	var cmd tea.Cmd
+	cur := m.current()
 	switch msg := msg.(type) {
 	case signal.Size:
 		m.width = msg.Width
 		m.height = msg.Height
-		m.textInput.Width = m.width - 8
+		m.composer.SetWidth(m.width - 6)
+		m.resizeComposer()
 	case signal.HomeTabSelected:
 		m.focus = bool(msg)
 		if m.focus {
-			m.textInput.Focus()
+			m.composer.Focus()
 		} else {
-			m.textInput.Blur()
+			m.composer.Blur()
 		}
 	case tea.KeyMsg:
+		if !m.focus {
+			break
+		}
+		if m.picker != nil {
+			cmds = append(cmds, m.updatePicker(cur, msg))
+			break
+		}
+		if cur != nil {
+			if ok, cmd := m.updateSearch(cur, msg); ok {
+				cmds = append(cmds, cmd)
+				break
+			}
+		}
+		if m.updateSuggestions(cur, msg) {
+			break
+		}
 		switch msg.String() {
 		case "down":
-			if m.focus {
-				m.offset--
-				if m.offset < 0 {
-					m.offset = 0
+			// Move within a multi-line draft before scrolling the messages.
+			if m.composer.Line() < m.composer.LineCount()-1 {
+				m.composer, cmd = m.composer.Update(msg)
+				cmds = append(cmds, cmd)
+				break
+			}
+			if cur != nil {
+				cur.offset--
+				if cur.offset < 0 {
+					cur.offset = 0
 				}
 			}
 		case "up":
-			if m.focus {
-				m.offset++
+			if m.composer.Line() > 0 {
+				m.composer, cmd = m.composer.Update(msg)
+				cmds = append(cmds, cmd)
+				break
+			}
+			if cur == nil {
+				break
+			}
+			// Past the top of what is loaded, ask the server for older pages.
+			lines, _ := m.renderLines(cur)
+			if cur.offset >= len(lines)-m.contentHeight() {
+				remote := cur.search != nil && cur.search.showingRemote()
+				if cur.thread == "" && !remote && !cur.historyLoading && !cur.historyDone && cur.connection != nil {
+					cur.historyLoading = true
+					cmds = append(cmds, FetchHistory(m.client, cur.key, cur.connection, cur.target, cur.oldest()))
+				}
+				break
+			}
+			cur.offset++
+		case "ctrl+n":
+			cmds = append(cmds, m.switchTo(m.active+1))
+		case "ctrl+p":
+			cmds = append(cmds, m.switchTo(m.active-1))
+		case "ctrl+x":
+			m.closeCurrent()
+		case "ctrl+r":
+			m.raw = !m.raw
+		case "alt+r":
+			m.openPicker(cur)
+		case "ctrl+f":
+			cmds = append(cmds, m.openSearch(cur))
+		case "ctrl+t":
+			if !m.openThread(cur) {
+				m.composer, cmd = m.composer.Update(msg)
+				cmds = append(cmds, cmd)
+			}
+		case "alt+up":
+			m.moveSelection(cur, -1)
+		case "alt+down":
+			m.moveSelection(cur, 1)
+		case "esc":
+			m.cancelSelection(cur)
+		case "ctrl+e":
+			if !m.startEdit(cur) {
+				m.composer, cmd = m.composer.Update(msg)
+				cmds = append(cmds, cmd)
+			}
+		case "ctrl+d":
+			if ok, cmd := m.deleteSelected(cur); ok {
+				cmds = append(cmds, cmd)
+			} else {
+				m.composer, cmd = m.composer.Update(msg)
+				cmds = append(cmds, cmd)
 			}
 		case "enter":
-			if m.focus && m.connection != nil && !m.loading {
-				val := m.textInput.Value()
-				if val == "" {
+			val := m.composer.Value()
+			if strings.TrimSpace(val) == "" {
+				break
+			}
+			// Commands run with no conversation open, or while it is
+			// offline; each checks for what it needs.
+			if (cur == nil || cur.editing == "") && isCommand(val) {
+				m.composer.Reset()
+				m.resizeComposer()
+				out := m.lobby
+				if cur != nil {
+					out = cur
+					cmds = append(cmds, cur.typing.stop(cur))
+				}
+				cmds = append(cmds, m.runCommand(out, val))
+				break
+			}
+			if cur != nil && cur.connection != nil && !cur.loading {
+				if cur.unencrypted() && !cur.plaintextConfirmed {
+					cur.plaintextConfirmed = true
+					cur.local(dto.TypeError, fmt.Sprintf("%s has no encryption key, so this message is not encrypted; press enter again to send it anyway", cur.target.Value))
 					break
 				}
-				m.textInput.SetValue("")
-				err := m.connection.WriteMessage(websocket.TextMessage, []byte(val))
-				if err != nil {
-					cmds = append(cmds, func() tea.Msg {
-						return chatError(err)
-					})
+				m.composer.Reset()
+				m.resizeComposer()
+				cmds = append(cmds, cur.typing.stop(cur))
+
+				if cur.editing == "" && strings.HasPrefix(val, "//") {
+					val = val[1:]
+				}
+
+				t, payload := dto.TypeMessage, dto.Message{Data: val, Parent: cur.thread}
+				if cur.editing != "" {
+					t, payload = dto.TypeEdit, dto.Message{ID: cur.editing, Data: val}
+					cur.editing = ""
+					cur.selected = ""
+					m.restoreDraft(cur)
 				}
+				cmds = append(cmds, cur.send(t, payload))
 			}
 		default:
-			m.textInput, cmd = m.textInput.Update(msg)
+			before := m.composer.Value()
+			m.composer, cmd = m.composer.Update(msg)
 			cmds = append(cmds, cmd)
+			m.resizeComposer()
 
+			if cur != nil && cur.connection != nil && m.composer.Value() != before {
+				if m.composer.Value() == "" {
+					cmds = append(cmds, cur.typing.stop(cur))
+				} else {
+					cmds = append(cmds, cur.typing.keystroke(cur))
+				}
+			}
 		}
+		m.syncCompletion()
 
-	case signal.Connect:
-		m.data = nil
-		m.error = nil
-		m.loading = true
-		name := strings.Split(msg.Value, ":")[0]
-		if msg.IsRoom {
-			m.title = "Room: " + name
-		} else {
-			m.title = "Chat with: " + msg.Value
+	case signal.UserInfo:
+		m.openStore(msg.Name)
+		cmds = append(cmds, m.openKeys(msg.Name))
+
+	case UserListResult:
+		if msg.Err == nil {
+			m.users = msg.Value
 		}
-		cmd := ConnectWS(m.connection, msg)
+
+	case RoomListResult:
+		if msg.Err == nil {
+			m.rooms = make([]string, len(msg.Value))
+			for i, v := range msg.Value {
+				m.rooms[i] = v.Name
+			}
+		}
+
+	case signal.Connect:
+		_, cmd = m.open(msg)
 		cmds = append(cmds, cmd)
 
 	case tea.QuitMsg:
-		if m.connection != nil {
-			m.connection.Close()
+		for _, c := range m.conversations {
+			c.close()
 		}
 
 	case chatConn:
-		m.loading = false
-		m.connection = msg
+		conv := m.find(msg.key)
+		if conv == nil || conv.dial != msg.dial {
+			// Closed, or dialed again, while we were dialing.
+			msg.connection.Close()
+			break
+		}
+		conv.loading = false
+		conv.connection = msg.connection
+		conv.reconnectAttempt = 0
+		conv.historyLoading = true
+		m.setPeerKey(conv, msg.peerKey)
+
+		for _, v := range conv.pending {
+			cmds = append(cmds, conv.send(dto.TypeMessage, v))
+		}
+		conv.pending = nil
 
 		cmds = append(cmds,
 			func() tea.Msg {
 				return signal.Refetch("all")
 			},
-			m.ReadMessage)
+			FetchHistory(m.client, conv.key, conv.connection, conv.target, dto.Message{}),
+			ReadMessage(conv.key, conv.connection))
+
+	case chatHistory:
+		conv := m.find(msg.key)
+		if conv == nil || conv.connection != msg.connection {
+			break
+		}
+		conv.historyLoading = false
+		conv.historyError = msg.err
+		if msg.err != nil {
+			break
+		}
+		if len(msg.messages) < historyPageSize {
+			conv.historyDone = true
+		}
+		for i := range msg.messages {
+			conv.unseal(&msg.messages[i])
+		}
+
+		loaded := map[string]bool{}
+		for _, v := range conv.data {
+			loaded[v.ID] = true
+		}
+		fresh := []chatMessage{}
+		for _, v := range msg.messages {
+			if v.ID == "" || !loaded[v.ID] {
+				fresh = append(fresh, v)
+			}
+		}
+		m.persist(conv, fresh...)
+		conv.data = mergeMessages(msg.messages, conv.data)
+
+	case chatStored:
+		// Copies the server already sent us are fresher, so they go first.
+		if conv := m.find(msg.key); conv != nil {
+			conv.data = mergeMessages(conv.data, msg.messages)
+		}
+
+	case chatReceived:
+		conv := m.find(msg.key)
+		// It is possible that the conversation was closed or reconnected
+		// while waiting
+		if conv == nil || conv.connection != msg.connection {
+			break
+		}
+		conv.unseal(&msg.message)
+		if msg.message.Kind == dto.TypeEdit || msg.message.Kind == dto.TypeDelete || msg.message.Kind == dto.TypeReact {
+			conv.applyChange(msg.message)
+			m.persistChange(conv, msg.message.ID)
+			cmds = append(cmds, ReadMessage(conv.key, conv.connection))
+			break
+		}
+		conv.data = append(conv.data, msg.message)
+		m.persist(conv, msg.message)
+		if msg.message.Kind == dto.TypeTopic {
+			conv.topic = msg.message.Data
+		}
+		if msg.message.Kind == dto.TypeMessage {
+			delete(conv.typing.others, msg.message.User)
+			if conv != cur {
+				conv.unread++
+				if m.mentionsUs(msg.message) {
+					conv.mentions++
+				}
+			}
+		}
+		cmds = append(cmds, ReadMessage(conv.key, conv.connection))
+	case chatSearch:
+		if conv := m.find(msg.key); conv != nil {
+			m.receiveSearch(conv, msg)
+		}
+
+	case chatHello:
+		conv := m.find(msg.key)
+		if conv == nil || conv.connection != msg.connection {
+			break
+		}
+		conv.limits = &msg.hello.Limits
+		conv.topic = msg.hello.Topic
+		if conv == cur {
+			m.applyLimits(conv)
+		}
+		cmds = append(cmds, ReadMessage(conv.key, conv.connection))
+
+	case typingReceived:
+		conv := m.find(msg.key)
+		if conv == nil || conv.connection != msg.connection {
+			break
+		}
+		if msg.typing.User != m.client.UserName() {
+			cmds = append(cmds, conv.typing.received(conv.key, msg.typing))
+		}
+		cmds = append(cmds, ReadMessage(conv.key, conv.connection))
+
+	case typingIdleCheck:
+		if conv := m.find(msg.key); conv != nil && conv.connection != nil {
+			cmds = append(cmds, conv.typing.idle(conv))
+		}
+
+	case keyVerified:
+		m.receiveVerify(msg)
+
+	case typingExpired:
+		if conv := m.find(msg.key); conv != nil {
+			conv.typing.prune()
+		}
 
-	case chatMessage:
-		m.data = append(m.data, msg)
-		cmds = append(cmds, m.ReadMessage)
 	case chatError:
-		m.error = msg
+		conv := m.find(msg.key)
+		if conv == nil || (msg.connection != nil && conv.connection != msg.connection) {
+			break
+		}
+		if msg.dial != 0 && msg.dial != conv.dial {
+			break
+		}
+		conv.close()
+		conv.loading = false
+		conv.typing = typingState{}
+
+		// An expired token is not permanent: the dial waits for a new one.
+		if request.IsPermanent(msg.err) && !request.IsUnauthorized(msg.err) {
+			conv.reconnectAttempt = 0
+			conv.error = msg.err
+			if websocket.IsCloseError(msg.err, websocket.CloseNormalClosure) {
+				conv.error = fmt.Errorf("connection closed by server")
+			}
+			break
+		}
+
+		conv.reconnectAttempt++
+		cmds = append(cmds, scheduleReconnect(conv.key, conv.reconnectAttempt))
+
+	case chatReconnect:
+		conv := m.find(msg.key)
+		// Re-selecting the conversation may have dialed in the meantime.
+		if conv == nil || conv.reconnectAttempt == 0 || conv.connection != nil || conv.loading {
+			break
+		}
+		conv.loading = true
+		cmds = append(cmds, m.dial(conv))
 	}
 
 	return m, tea.Batch(cmds...)
 }
 
-func (m *Chat) View() string {
-	var tabStyle lip.Style
+// open shows the conversation with target, dialing it unless it is already
+// connected.
+func (m *Chat) open(target signal.Connect) (*conversation, tea.Cmd) {
+	var cmds []tea.Cmd
+	key := conversationKey(target)
+	conv := m.find(key)
+	if conv == nil {
+		conv = newConversation(target)
+		m.conversations = append(m.conversations, conv)
+		if m.store != nil {
+			cmds = append(cmds, LoadStored(m.store, key))
+		}
+	}
+	for i, c := range m.conversations {
+		if c == conv {
+			cmds = append(cmds, m.switchTo(i))
+		}
+	}
 
-	if m.focus {
-		tabStyle = design.ActiveTab
-	} else {
-		tabStyle = design.Tab
+	// Re-selecting a live conversation only brings it to the front.
+	if conv.connection != nil || conv.loading {
+		return conv, tea.Batch(cmds...)
+	}
+	conv.target = target
+	conv.error = nil
+	conv.loading = true
+	conv.historyDone = false
+	cmds = append(cmds, m.dial(conv))
+	return conv, tea.Batch(cmds...)
+}
+
+// stripView renders the list of open conversations with their unread counts.
+// When it doesn't fit, conversations before the active one are dropped.
+func (m *Chat) stripView(width int) string {
+	if len(m.conversations) == 0 {
+		return lip.NewStyle().Foreground(design.Subtle).Render("No open conversations")
+	}
+
+	items := make([]string, len(m.conversations))
+	for i, c := range m.conversations {
+		label := c.label
+		if c.mentions > 0 {
+			label += mentionCountStyle.Render(fmt.Sprintf(" @%d", c.mentions))
+		}
+		if c.unread > 0 {
+			label += lip.NewStyle().Foreground(design.Special).Render(fmt.Sprintf(" (%d)", c.unread))
+		}
+		if i == m.active {
+			label = lip.NewStyle().Foreground(design.Highlight).Bold(true).Render(label)
+		}
+		items[i] = label
 	}
 
-	text := []string{}
+	sep := lip.NewStyle().Foreground(design.Subtle).Render(" │ ")
+	first := 0
+	for first < m.active && lip.Width(strings.Join(items[first:], sep)) > width {
+		first++
+	}
+	return lip.NewStyle().MaxWidth(width).Render(strings.Join(items[first:], sep))
+}
+
+func (m *Chat) contentHeight() int {
+	return max(m.height-6-m.composer.Height(), 0)
+}
+
+// renderLines renders every loaded message of conv, one terminal line per
+// entry. starts holds the index of the first line of each message.
+func (m *Chat) renderLines(conv *conversation) (text []string, starts []int) {
+	data := visible(conv)
+	replies := replyCounts(conv)
+	var mark *regexp.Regexp
+	if conv.search != nil {
+		mark = conv.search.re
+	}
+	text = []string{}
+	starts = make([]int, len(data))
 
 	prevUser := ""
-	for _, v := range m.data {
-		rendered := lip.NewStyle().
+	for i, v := range data {
+		starts[i] = len(text)
+		if v.Kind != dto.TypeMessage {
+			text = append(text, renderEvent(v, m.width-4))
+			prevUser = ""
+			continue
+		}
+
+		body := v.Data
+		if v.Deleted {
+			body = eventStyle.Render("message deleted")
+		} else {
+			if m.options.Markdown && !m.raw {
+				body = renderMarkdown(body, mark)
+			} else {
+				body = markText(body, lip.NewStyle(), mark)
+			}
+			if v.Action {
+				body = actionStyle.Render("* "+v.User) + " " + body
+			}
+			if v.Edited {
+				body += " " + eventStyle.Render("(edited)")
+			}
+		}
+
+		bubble := lip.NewStyle().
 			Border(lip.RoundedBorder()).
 			Padding(0, 1).
-			MaxWidth(m.width - 4).
-			Render(v.Message)
+			MaxWidth(m.width - 4)
+		if v.ID != "" && v.ID == conv.selected {
+			bubble = bubble.BorderForeground(design.Highlight)
+		} else if conv.search != nil && v.ID != "" && v.ID == conv.search.current {
+			bubble = bubble.BorderForeground(design.Away)
+		} else if m.mentionsUs(v) {
+			bubble = bubble.BorderForeground(design.Mention)
+		}
+		rendered := bubble.Render(body)
 
+		// Inside a thread every reply has the same parent, so only the
+		// timeline quotes it.
+		if v.Parent != "" && conv.thread == "" {
+			rendered = lipgloss.JoinVertical(lip.Top, quoteLine(conv, v.Parent, m.width-4), rendered)
+		}
 		if prevUser != v.User {
 			rendered = lipgloss.JoinVertical(lip.Top,
 				lip.NewStyle().Foreground(lip.Color("205")).Bold(true).Render(v.User)+" "+v.Timestamp.Local().Format("15:04"),
 				rendered,
 			)
 		}
+		footer := []string{}
+		if len(v.Reactions) > 0 && !v.Deleted {
+			footer = append(footer, renderReactions(v, m.client.UserName()))
+		}
+		if n := replies[v.ID]; n > 0 && conv.thread == "" {
+			footer = append(footer, replyBadge(n))
+		}
+		if len(footer) > 0 {
+			rendered = lipgloss.JoinVertical(lip.Top, rendered, strings.Join(footer, " "))
+		}
 		prevUser = v.User
 		text = append(text, strings.Split(rendered, "\n")...)
 	}
 
-	contentHeight := m.height - 5
-	if contentHeight < 0 {
-		contentHeight = 0
+	return text, starts
+}
+
+var (
+	eventStyle  = lip.NewStyle().Foreground(lip.Color("241")).Italic(true)
+	actionStyle = lip.NewStyle().Foreground(lip.Color("205")).Italic(true)
+)
+
+// renderEvent renders a system event as a single dimmed line so it stands
+// apart from message bubbles.
+func renderEvent(v chatMessage, width int) string {
+	var text string
+	switch v.Kind {
+	case dto.TypeJoin:
+		text = fmt.Sprintf("→ %s joined", v.User)
+	case dto.TypeLeave:
+		text = fmt.Sprintf("← %s left", v.User)
+	case dto.TypeTopic:
+		text = fmt.Sprintf("• %s set the topic: %s", v.User, v.Data)
+		if v.Data == "" {
+			text = fmt.Sprintf("• %s cleared the topic", v.User)
+		}
+	case dto.TypeError:
+		return design.ErrorText.MaxWidth(width).Render("✗ " + v.Data)
+	default:
+		text = "• " + v.Data
 	}
+	return eventStyle.MaxWidth(width).Render(text + " " + v.Timestamp.Local().Format("15:04"))
+}
+
+func (m *Chat) View() string {
+	var tabStyle lip.Style
+
+	if m.focus {
+		tabStyle = design.ActiveTab
+	} else {
+		tabStyle = design.Tab
+	}
+
+	cur := m.current()
+	shown := cur
+	if shown == nil {
+		shown = m.lobby
+	}
+
+	text, _ := m.renderLines(shown)
+
+	contentHeight := m.contentHeight()
 
 	if len(text) > contentHeight {
-		if m.offset > len(text)-m.height+5 {
-			m.offset = len(text) - m.height + 5
+		if shown.offset > len(text)-contentHeight {
+			shown.offset = len(text) - contentHeight
+		}
+		text = text[len(text)-contentHeight-shown.offset : len(text)-shown.offset]
+	}
+
+	if cur != nil && (cur.historyLoading || cur.historyError != nil) && contentHeight > 0 {
+		top := lip.NewStyle().Foreground(design.Subtle).Render("loading older messages " + common.Spinner.View())
+		if cur.historyError != nil {
+			top = design.ErrorText.Render(cur.historyError.Error())
+		}
+		if len(text) == contentHeight {
+			text[0] = top
+		} else {
+			text = append([]string{top}, text...)
 		}
-		text = text[len(text)-m.height+5-m.offset : len(text)-m.offset]
 	}
 
-	if m.error != nil {
-		text = []string{design.ErrorText.Render(m.error.Error())}
+	if cur != nil && cur.error != nil {
+		text = []string{design.ErrorText.Render(cur.error.Error())}
 	}
 
-	res := make([]string, contentHeight+2)
+	res := make([]string, contentHeight+3)
 	title := m.title
 
-	if m.loading {
-		title = title + " " + common.Spinner.View()
+	if cur != nil {
+		title = cur.title
+		if cur.keyChanged {
+			title += " ⚠"
+		} else if cur.shared != nil {
+			title += " 🔒"
+		}
+		if m.identity != nil && !cur.target.IsRoom && cur.connection != nil && cur.shared == nil {
+			title += " 🔓 not encrypted"
+		}
+		if cur.thread != "" {
+			title += " › thread"
+		} else if cur.topic != "" {
+			title += " — " + truncate(cur.topic, max(m.width-4-lip.Width(title)-3, 1))
+		}
+		if m.options.Markdown && m.raw {
+			title += " [raw]"
+		}
+		if status := searchStatus(cur); status != "" {
+			title += " " + status
+		}
+		if cur.reconnectAttempt > 0 {
+			title = fmt.Sprintf("%s reconnecting (attempt %d)… %s", title, cur.reconnectAttempt, common.Spinner.View())
+		} else if cur.loading {
+			title = title + " " + common.Spinner.View()
+		}
 	}
 
-	res[0] = design.ListHeader.Width(m.width - 4).Render(
+	res[0] = m.stripView(m.width - 4)
+	res[1] = design.ListHeader.Width(m.width - 4).Render(
 		title,
 	)
 
 	for i, v := range text {
-		res[i+1] = v
+		res[i+2] = v
 	}
 
-	if m.focus && !m.loading && m.connection != nil {
-		res[len(res)-1] = m.textInput.View()
+	// The mention popup covers the bottom of the messages, just above the
+	// composer.
+	_, suggestions := m.suggestions(cur)
+	if len(suggestions) > 0 {
+		lines := m.suggestionLines(suggestions, m.width-4)
+		first := max(len(res)-1-len(lines), 2)
+		for i := first; i < len(res)-1; i++ {
+			res[i] = lines[i-first]
+		}
+	}
+
+	if cur != nil {
+		status := cur.typing.String()
+		if status == "" && cur.thread != "" {
+			status = "replying in thread, esc to go back"
+		}
+		if cur.editing != "" {
+			status = "editing message, esc to cancel"
+		}
+		if len(suggestions) > 0 {
+			status = "tab to complete, esc to dismiss"
+		}
+		if cur.search != nil {
+			status = searchHelp(cur, m.width-4)
+		}
+		if m.picker != nil {
+			status = m.picker.View(m.width - 4)
+		}
+		res[len(res)-1] = eventStyle.MaxWidth(m.width - 4).Render(status)
+	}
+
+	composer := strings.Repeat("\n", m.composer.Height()-1)
+	if m.focus {
+		m.composer.Placeholder = "Type a message..."
+		if cur != nil && cur.unencrypted() {
+			m.composer.Placeholder = "Type a message... 🔓 not encrypted"
+		}
+		composer = m.composer.View()
 	}
+	res = append(res, composer)
 
 	return tabStyle.
 		Width(m.width - 2).
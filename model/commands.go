@@ -0,0 +1,276 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/signal"
+)
+
+// errUsage makes a command print its usage line.
+var errUsage = errors.New("usage")
+
+// Errors of commands that need a conversation, or its connection.
+var (
+	errNoConversation = errors.New("open a conversation first, with /join or /msg")
+	errOffline        = errors.New("not connected, wait for the conversation to reconnect")
+)
+
+// command is a slash command typed into the composer.
+type command struct {
+	name  string
+	usage string
+	help  string
+	// complete lists the candidates for the first argument.
+	complete func(m *Chat) []string
+	run      func(m *Chat, conv *conversation, args string) (tea.Cmd, error)
+}
+
+// commands is the registry of slash commands, in the order /help lists them.
+// It is filled in by init since /help refers back to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{
+			name:     "join",
+			usage:    "join room [password]",
+			help:     "join or create a room",
+			complete: func(m *Chat) []string { return m.rooms },
+			run:      runJoin,
+		},
+		{
+			name:     "msg",
+			usage:    "msg user [text]",
+			help:     "open a direct message, optionally sending text",
+			complete: func(m *Chat) []string { return m.mentionCandidates(nil) },
+			run:      runMsg,
+		},
+		{
+			name:  "me",
+			usage: "me action",
+			help:  "send an action, like * you wave",
+			run:   runMe,
+		},
+		{
+			name:  "leave",
+			usage: "leave",
+			help:  "close this conversation",
+			run:   runLeave,
+		},
+		{
+			name:  "clear",
+			usage: "clear",
+			help:  "clear the messages on screen",
+			run:   runClear,
+		},
+		{
+			name:  "topic",
+			usage: "topic [text]",
+			help:  "show or set the room topic",
+			run:   runTopic,
+		},
+		{
+			name:     "verify",
+			usage:    "verify user [trust]",
+			help:     "show the safety number of encrypted messages with user",
+			complete: func(m *Chat) []string { return m.mentionCandidates(nil) },
+			run:      runVerify,
+		},
+		{
+			name:  "logout",
+			usage: "logout",
+			help:  "log out and forget the remembered session",
+			run:   runLogout,
+		},
+		{
+			name:     "help",
+			usage:    "help [command]",
+			help:     "list commands",
+			complete: commandNames,
+			run:      runHelp,
+		},
+	}
+}
+
+func lookupCommand(name string) (*command, bool) {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i], true
+		}
+	}
+	return nil, false
+}
+
+func commandNames(*Chat) []string {
+	res := make([]string, len(commands))
+	for i, v := range commands {
+		res[i] = v.name
+	}
+	return res
+}
+
+// isCommand reports whether line should run a command rather than be sent.
+// A leading // sends a message that starts with a slash.
+func isCommand(line string) bool {
+	return strings.HasPrefix(line, "/") && !strings.HasPrefix(line, "//")
+}
+
+// runCommand parses and runs line. Mistakes are shown as local error lines
+// in conv and never reach the server.
+func (m *Chat) runCommand(conv *conversation, line string) tea.Cmd {
+	name, args, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "/"), " ")
+	cmd, ok := lookupCommand(strings.ToLower(name))
+	if !ok {
+		conv.local(dto.TypeError, fmt.Sprintf("unknown command /%s, type /help for a list", name))
+		return nil
+	}
+
+	res, err := cmd.run(m, conv, strings.TrimSpace(args))
+	if errors.Is(err, errUsage) {
+		conv.local(dto.TypeError, "usage: /"+cmd.usage)
+	} else if err != nil {
+		conv.local(dto.TypeError, err.Error())
+	}
+	return res
+}
+
+// commandSuggestions completes command names, and the first argument of
+// commands that know how.
+func (m *Chat) commandSuggestions(text string) (int, []suggestion) {
+	if !isCommand(text) {
+		return 0, nil
+	}
+
+	name, arg, hasArg := strings.Cut(text[1:], " ")
+	if !hasArg {
+		res := []suggestion{}
+		for _, v := range commands {
+			if strings.HasPrefix(v.name, strings.ToLower(name)) {
+				res = append(res, suggestion{value: "/" + v.name, label: fmt.Sprintf("/%s  %s", v.usage, v.help)})
+			}
+		}
+		return 0, res
+	}
+
+	cmd, ok := lookupCommand(strings.ToLower(name))
+	if !ok || cmd.complete == nil || strings.Contains(arg, " ") {
+		return 0, nil
+	}
+	names := matchPrefix(cmd.complete(m), arg)
+	res := make([]suggestion, len(names))
+	for i, v := range names {
+		res[i] = suggestion{value: v, label: v}
+	}
+	return len(text) - len(arg), res
+}
+
+func runJoin(m *Chat, conv *conversation, args string) (tea.Cmd, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, errUsage
+	}
+	if strings.ContainsAny(fields[0], ":/") {
+		return nil, errors.New("room names can't contain : or /")
+	}
+
+	target := signal.Connect{IsRoom: true, Value: fields[0]}
+	if len(fields) == 2 {
+		target.Password = fields[1]
+	}
+	_, cmd := m.open(target)
+	return cmd, nil
+}
+
+func runMsg(m *Chat, conv *conversation, args string) (tea.Cmd, error) {
+	user, text, _ := strings.Cut(args, " ")
+	if user == "" {
+		return nil, errUsage
+	}
+
+	next, cmd := m.open(signal.Connect{Value: strings.TrimPrefix(user, "@")})
+	if text = strings.TrimSpace(text); text == "" {
+		return cmd, nil
+	}
+	if next.connection == nil {
+		next.pending = append(next.pending, dto.Message{Data: text})
+		return cmd, nil
+	}
+	return tea.Batch(cmd, next.send(dto.TypeMessage, dto.Message{Data: text})), nil
+}
+
+func runMe(m *Chat, conv *conversation, args string) (tea.Cmd, error) {
+	if args == "" {
+		return nil, errUsage
+	}
+	if conv == m.lobby {
+		return nil, errNoConversation
+	}
+	if conv.connection == nil {
+		return nil, errOffline
+	}
+	return conv.send(dto.TypeMessage, dto.Message{Data: args, Action: true, Parent: conv.thread}), nil
+}
+
+func runLeave(m *Chat, conv *conversation, args string) (tea.Cmd, error) {
+	m.closeCurrent()
+	return nil, nil
+}
+
+func runClear(m *Chat, conv *conversation, args string) (tea.Cmd, error) {
+	m.closeThread(conv)
+	conv.data = nil
+	conv.offset = 0
+	conv.selected = ""
+	// Scrolling up shouldn't bring back what was just cleared.
+	conv.historyDone = true
+	return nil, nil
+}
+
+func runTopic(m *Chat, conv *conversation, args string) (tea.Cmd, error) {
+	if conv == m.lobby {
+		return nil, errNoConversation
+	}
+	if !conv.target.IsRoom {
+		return nil, errors.New("direct messages don't have a topic")
+	}
+	if args == "" {
+		if conv.topic == "" {
+			conv.local(dto.TypeSystem, "no topic is set")
+		} else {
+			conv.local(dto.TypeSystem, "topic: "+conv.topic)
+		}
+		return nil, nil
+	}
+	if conv.connection == nil {
+		return nil, errOffline
+	}
+	if conv.connection.Protocol == 0 {
+		return nil, errors.New("this server doesn't support topics")
+	}
+	return conv.send(dto.TypeTopic, dto.Topic{Text: args}), nil
+}
+
+func runLogout(m *Chat, conv *conversation, args string) (tea.Cmd, error) {
+	return logout(m.client), nil
+}
+
+func runHelp(m *Chat, conv *conversation, args string) (tea.Cmd, error) {
+	if args != "" {
+		cmd, ok := lookupCommand(strings.TrimPrefix(args, "/"))
+		if !ok {
+			return nil, fmt.Errorf("unknown command /%s", strings.TrimPrefix(args, "/"))
+		}
+		conv.local(dto.TypeSystem, fmt.Sprintf("/%s  %s", cmd.usage, cmd.help))
+		return nil, nil
+	}
+
+	for _, v := range commands {
+		conv.local(dto.TypeSystem, fmt.Sprintf("/%s  %s", v.usage, v.help))
+	}
+	conv.local(dto.TypeSystem, "start a message with // to send it with a leading slash")
+	return nil, nil
+}
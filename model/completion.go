@@ -0,0 +1,127 @@
+package model
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	lip "github.com/charmbracelet/lipgloss"
+	"github.com/onfirebyte/chatt/design"
+)
+
+// maxSuggestions is how many entries the completion popup lists at once.
+const maxSuggestions = 5
+
+var (
+	suggestionStyle         = lip.NewStyle().Foreground(lip.Color("246"))
+	selectedSuggestionStyle = lip.NewStyle().Foreground(design.Highlight).Bold(true)
+)
+
+// suggestion is one entry of the completion popup. value replaces the word
+// being completed; label is what the popup shows.
+type suggestion struct {
+	value string
+	label string
+}
+
+// completionState is the popup for the word being typed at the end of the
+// composer. It is reset whenever the composer changes.
+type completionState struct {
+	text      string
+	idx       int
+	dismissed bool
+}
+
+// suggestions returns what tab could complete at the end of the composer and
+// the offset of the word it would replace. It returns nil when the popup is
+// closed. Commands complete with no conversation open and while offline,
+// like they run; mentions need the members of a connected conversation.
+func (m *Chat) suggestions(conv *conversation) (int, []suggestion) {
+	if !m.focus || (conv != nil && conv.editing != "") || m.completion.dismissed {
+		return 0, nil
+	}
+
+	value := m.composer.Value()
+	if start, res := m.commandSuggestions(value); len(res) > 0 {
+		return start, res
+	}
+	if conv == nil || conv.connection == nil {
+		return 0, nil
+	}
+	return m.mentionSuggestions(conv, value)
+}
+
+// syncCompletion resets the popup when the composer changed.
+func (m *Chat) syncCompletion() {
+	if value := m.composer.Value(); value != m.completion.text {
+		m.completion = completionState{text: value}
+	}
+}
+
+// complete replaces the word being typed with the highlighted suggestion.
+func (m *Chat) complete(conv *conversation) bool {
+	start, res := m.suggestions(conv)
+	if len(res) == 0 {
+		return false
+	}
+
+	value := m.composer.Value()
+	m.composer.SetValue(value[:start] + res[min(m.completion.idx, len(res)-1)].value + " ")
+	m.resizeComposer()
+	m.syncCompletion()
+	return true
+}
+
+// moveSuggestion moves the popup highlight by dir, reporting false if the
+// popup is closed.
+func (m *Chat) moveSuggestion(conv *conversation, dir int) bool {
+	_, res := m.suggestions(conv)
+	if len(res) == 0 {
+		return false
+	}
+	m.completion.idx = (m.completion.idx + dir + len(res)) % len(res)
+	return true
+}
+
+// suggestionLines renders the popup, one entry per line, scrolled so the
+// highlighted entry is shown.
+func (m *Chat) suggestionLines(res []suggestion, width int) []string {
+	first := max(m.completion.idx-maxSuggestions+1, 0)
+	end := min(first+maxSuggestions, len(res))
+
+	lines := []string{}
+	for i := first; i < end; i++ {
+		if i == m.completion.idx {
+			lines = append(lines, selectedSuggestionStyle.MaxWidth(width).Render("▸ "+res[i].label))
+		} else {
+			lines = append(lines, suggestionStyle.MaxWidth(width).Render("  "+res[i].label))
+		}
+	}
+	return lines
+}
+
+// completing reports whether tab would complete something.
+func (m *Chat) completing() bool {
+	if !m.focus || m.picker != nil {
+		return false
+	}
+	_, res := m.suggestions(m.current())
+	return len(res) > 0
+}
+
+// updateSuggestions handles the keys of the completion popup. It reports
+// false for keys the popup doesn't use, and when it is closed.
+func (m *Chat) updateSuggestions(conv *conversation, msg tea.KeyMsg) bool {
+	switch msg.String() {
+	case "tab":
+		return m.complete(conv)
+	case "up":
+		return m.moveSuggestion(conv, -1)
+	case "down":
+		return m.moveSuggestion(conv, 1)
+	case "esc":
+		if _, res := m.suggestions(conv); len(res) == 0 {
+			return false
+		}
+		m.completion.dismissed = true
+		return true
+	}
+	return false
+}
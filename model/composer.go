@@ -0,0 +1,58 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	lip "github.com/charmbracelet/lipgloss"
+	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/request"
+)
+
+// defaultMessageLimit applies until the server advertises its own limit, and
+// for servers that never do.
+const defaultMessageLimit = 128
+
+type chatHello struct {
+	key        string
+	connection *request.Conn
+	hello      dto.Hello
+}
+
+func newComposer() textarea.Model {
+	ta := textarea.New()
+	ta.Placeholder = "Type a message..."
+	ta.ShowLineNumbers = false
+	ta.Prompt = "> "
+	ta.CharLimit = defaultMessageLimit
+	ta.FocusedStyle.CursorLine = lip.NewStyle()
+	ta.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("alt+enter", "ctrl+j"))
+	ta.SetHeight(1)
+	ta.Blur()
+	return ta
+}
+
+// composerRows is how many rows the draft needs once soft-wrapped, capped
+// at the configured height.
+func (m *Chat) composerRows() int {
+	width := max(m.composer.Width(), 1)
+	rows := 0
+	for _, line := range strings.Split(m.composer.Value(), "\n") {
+		rows += max((lip.Width(line)+width-1)/width, 1)
+	}
+	return min(max(rows, 1), max(m.options.ComposerHeight, 1))
+}
+
+func (m *Chat) resizeComposer() {
+	m.composer.SetHeight(m.composerRows())
+}
+
+// applyLimits sets the composer length limit for conv.
+func (m *Chat) applyLimits(conv *conversation) {
+	limit := defaultMessageLimit
+	if conv != nil && conv.limits != nil {
+		limit = conv.limits.MaxMessageLength
+	}
+	m.composer.CharLimit = limit
+}
@@ -0,0 +1,286 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/e2e"
+	"github.com/onfirebyte/chatt/request"
+	"github.com/onfirebyte/chatt/signal"
+)
+
+// conversation is one open room or direct message in the chat pane. Each has
+// its own connection, so conversations in the background keep receiving
+// messages while another one is shown.
+type conversation struct {
+	key     string
+	target  signal.Connect
+	title   string
+	label   string
+	loading bool
+
+	connection *request.Conn
+	// dial is the number of the latest dial; results of older ones are
+	// dropped.
+	dial uint64
+
+	data   []chatMessage
+	error  error
+	offset int
+	draft  string
+	unread int
+	// mentions counts unread messages that mention us.
+	mentions int
+
+	historyLoading bool
+	historyDone    bool
+	historyError   error
+
+	typing typingState
+
+	// selected is the ID of the message picked with alt+up/down, and editing
+	// the ID of the message the composer is editing.
+	selected string
+	editing  string
+
+	// thread is the ID of the message whose replies are shown instead of
+	// the timeline. The timeline's scroll position is kept in mainOffset.
+	thread      string
+	threadDraft string
+	mainOffset  int
+
+	search *searchState
+	// limits is nil until the server sends its hello.
+	limits *dto.Limits
+	topic  string
+
+	// pending holds messages written before the connection was up.
+	pending []dto.Message
+
+	// peerKey is the other user's key in an encrypted direct message and
+	// shared the key its messages are sealed with; both are nil for
+	// plaintext. keyChanged is set when peerKey is not the key we remember,
+	// or is missing while we remember one, and stops us from sending until
+	// it is settled with /verify.
+	peerKey    *e2e.Key
+	shared     *e2e.Key
+	keyChanged bool
+	// wantsEncryption is set for direct messages when encryption is on.
+	// plaintextConfirmed is set once the user was warned that one of them
+	// goes out unencrypted, so the next message is sent anyway.
+	wantsEncryption    bool
+	plaintextConfirmed bool
+	// self is our name, sealed into every box along with the other user's.
+	self string
+	// replays catches boxes the server posts again as new messages.
+	replays *e2e.Replays
+
+	// reconnectAttempt counts failed attempts since the connection dropped;
+	// zero means we aren't reconnecting.
+	reconnectAttempt int
+}
+
+func conversationKey(target signal.Connect) string {
+	if target.IsRoom {
+		return "#" + roomName(target.Value)
+	}
+	return "@" + target.Value
+}
+
+func roomName(value string) string {
+	return strings.Split(value, ":")[0]
+}
+
+func newConversation(target signal.Connect) *conversation {
+	c := &conversation{
+		key:    conversationKey(target),
+		target: target,
+	}
+	if target.IsRoom {
+		c.title = "Room: " + roomName(target.Value)
+		c.label = "#" + roomName(target.Value)
+	} else {
+		c.title = "Chat with: " + target.Value
+		c.label = "@" + target.Value
+	}
+	return c
+}
+
+// mergeMessages combines a page of history with the messages already loaded,
+// dropping duplicates and keeping them in timestamp order.
+func mergeMessages(older []chatMessage, loaded []chatMessage) []chatMessage {
+	type messageKey struct {
+		kind      dto.EnvelopeType
+		user      string
+		data      string
+		timestamp int64
+	}
+
+	seen := map[messageKey]bool{}
+	byID := map[string]int{}
+	res := make([]chatMessage, 0, len(older)+len(loaded))
+	for _, v := range append(older, loaded...) {
+		if v.ID != "" {
+			// Keep whichever copy has seen the most changes, or that we
+			// could decrypt and verify.
+			if i, ok := byID[v.ID]; ok {
+				if v.Deleted || (v.Edited && !res[i].Deleted) || (res[i].Sealed && !v.Sealed) || (res[i].Unverified && !v.Unverified && !v.Sealed) {
+					res[i] = v
+				}
+				continue
+			}
+			byID[v.ID] = len(res)
+			res = append(res, v)
+			continue
+		}
+
+		k := messageKey{v.Kind, v.User, v.Data, v.Timestamp.UnixNano()}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		res = append(res, v)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Timestamp.Before(res[j].Timestamp)
+	})
+	return res
+}
+
+// oldest returns where the next page of history starts: the oldest message
+// with an ID, or now when nothing was loaded from the server.
+func (c *conversation) oldest() dto.Message {
+	for _, v := range c.data {
+		if v.ID != "" {
+			return v.Message
+		}
+	}
+	if len(c.data) > 0 {
+		return dto.Message{Timestamp: c.data[0].Timestamp}
+	}
+	return dto.Message{Timestamp: time.Now()}
+}
+
+// applyChange updates a loaded message after an edit, delete or reaction.
+func (c *conversation) applyChange(change chatMessage) {
+	for i := range c.data {
+		if c.data[i].ID != change.ID {
+			continue
+		}
+		if change.Kind == dto.TypeReact {
+			c.data[i].Reactions = change.Reactions
+			continue
+		}
+		c.data[i].Data = change.Data
+		c.data[i].Sealed = change.Sealed
+		c.data[i].Unverified = change.Unverified
+		c.data[i].Edited = change.Edited
+		c.data[i].Deleted = change.Deleted
+	}
+	if change.Deleted && c.selected == change.ID {
+		c.selected = ""
+	}
+}
+
+// local adds a line to the timeline that only we see, such as the output
+// of a command.
+func (c *conversation) local(kind dto.EnvelopeType, text string) {
+	c.data = append(c.data, chatMessage{
+		Message: dto.Message{Data: text, Timestamp: time.Now()},
+		Kind:    kind,
+	})
+	c.offset = 0
+}
+
+// send writes one envelope, turning a failure into a chatError. Messages and
+// edits of an encrypted conversation are sealed first.
+func (c *conversation) send(t dto.EnvelopeType, payload any) tea.Cmd {
+	if c.connection == nil {
+		return nil
+	}
+	if _, ok := payload.(dto.Message); ok && t == dto.TypeMessage && c.unencrypted() && !c.plaintextConfirmed {
+		c.plaintextConfirmed = true
+		c.local(dto.TypeError, fmt.Sprintf("not sent: %s has no encryption key, so it would not be encrypted; send it again to send it anyway", c.target.Value))
+		return nil
+	}
+	if msg, ok := payload.(dto.Message); ok && (t == dto.TypeMessage || t == dto.TypeEdit) && (c.shared != nil || c.keyChanged) {
+		if c.keyChanged && c.peerKey == nil {
+			c.local(dto.TypeError, fmt.Sprintf("not sent: %s's key is gone, check it with /verify %s", c.target.Value, c.target.Value))
+			return nil
+		}
+		if c.keyChanged {
+			c.local(dto.TypeError, fmt.Sprintf("not sent: %s's key changed, check it with /verify %s", c.target.Value, c.target.Value))
+			return nil
+		}
+		h := e2e.Header{From: c.self, To: c.target.Value}
+		if t == dto.TypeEdit {
+			h.ID = msg.ID
+		}
+		sealed, err := e2e.Seal(c.shared, h, msg.Data)
+		if err != nil {
+			c.local(dto.TypeError, "not sent: "+err.Error())
+			return nil
+		}
+		msg.Data, msg.Sealed = sealed, true
+		payload = msg
+	}
+	if err := c.connection.Send(t, payload); err != nil {
+		key := c.key
+		return func() tea.Msg {
+			return chatError{key: key, err: err}
+		}
+	}
+	return nil
+}
+
+// unencrypted reports whether c is a direct message that goes out in
+// plaintext although encryption is on.
+func (c *conversation) unencrypted() bool {
+	return c.wantsEncryption && c.shared == nil && !c.keyChanged
+}
+
+// unseal decrypts a message of an encrypted conversation in place. One we
+// can't decrypt keeps Sealed, so it is never stored, and shows a notice.
+// Plaintext can only come from the server, since both sides seal everything,
+// so it is marked as unverified and not stored either, like a box that
+// already came with another message.
+func (c *conversation) unseal(v *chatMessage) {
+	if v.Deleted || (v.Kind != dto.TypeMessage && v.Kind != dto.TypeEdit) {
+		return
+	}
+	if !v.Sealed {
+		if c.shared != nil {
+			v.Data = "⚠ not encrypted, it may not be from " + v.User + ": " + v.Data
+			v.Unverified = true
+		}
+		return
+	}
+	if c.shared != nil {
+		h := e2e.Header{From: v.User, To: c.self, ID: v.ID}
+		if v.User == c.self {
+			h.To = c.target.Value
+		}
+		if text, err := e2e.Open(c.shared, h, v.Data); err == nil {
+			if c.replays.Replayed(v.Data, v.ID) {
+				text = "⚠ posted again by the server, it may not be from " + v.User + " now: " + text
+				v.Unverified = true
+			}
+			v.Data, v.Sealed = text, false
+			return
+		}
+	}
+	v.Data = "🔒 encrypted message that can't be decrypted here"
+}
+
+func (c *conversation) close() {
+	if c.connection != nil {
+		c.connection.Close()
+		c.connection = nil
+	}
+}
@@ -1,34 +1,70 @@
 package model
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"slices"
+	"strings"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/onfirebyte/chatt/common"
+	"github.com/onfirebyte/chatt/config"
+	"github.com/onfirebyte/chatt/design"
+	"github.com/onfirebyte/chatt/dto"
 	"github.com/onfirebyte/chatt/request"
 	"github.com/onfirebyte/chatt/signal"
 )
 
 type CreateUser struct {
+	client        *request.Client
 	userInput     textinput.Model
 	passwordInput textinput.Model
-	focusOnUser   bool
+	confirmInput  textinput.Model
+	focus         loginField
 	err           error
 	loading       bool
+
+	// register creates an account instead of logging in to one.
+	register bool
+
+	// remember saves the token so the next launch skips this screen.
+	remember bool
+	// saved is the remembered session being checked on startup.
+	saved *config.Session
 }
 
+// loginField is the part of the form that has focus.
+type loginField int
+
+const (
+	userField loginField = iota
+	passwordField
+	confirmField
+	rememberField
+)
+
 type createUserStatus struct {
-	token string
-	error error
+	session dto.Session
+	error   error
+}
+
+// sessionChecked is the server's answer about a remembered session.
+type sessionChecked struct {
+	session config.Session
+	error   error
 }
 
 var errorMsgStyle = lipgloss.NewStyle().
 	Foreground(lipgloss.Color("#FF0000")).
 	Bold(true)
 
-func NewCreateUserModel() CreateUser {
+// NewCreateUserModel returns the login screen, with the name filled in and
+// the password focused when name is not empty. A session remembered for the
+// server is tried first, unless it belongs to another name.
+func NewCreateUserModel(client *request.Client, name string) CreateUser {
 	userInput := textinput.New()
 	userInput.Placeholder = "Username"
 	userInput.Focus()
@@ -42,18 +78,128 @@ func NewCreateUserModel() CreateUser {
 	passwordInput.EchoMode = textinput.EchoPassword
 	passwordInput.EchoCharacter = '•'
 
-	return CreateUser{
+	confirmInput := textinput.New()
+	confirmInput.Placeholder = "Confirm password"
+	confirmInput.CharLimit = 156
+	confirmInput.Width = 20
+	confirmInput.EchoMode = textinput.EchoPassword
+	confirmInput.EchoCharacter = '•'
+
+	m := CreateUser{
+		client:        client,
 		userInput:     userInput,
 		passwordInput: passwordInput,
+		confirmInput:  confirmInput,
 		err:           nil,
-		focusOnUser:   true,
 	}
+
+	if name != "" {
+		m.userInput.SetValue(name)
+		m.setFocus(passwordField)
+	}
+
+	saved, ok, err := config.LoadSession(client.URL())
+	if err != nil {
+		log.Println("load session:", err)
+	} else if ok && (name == "" || name == saved.User) {
+		m.saved = &saved
+		m.remember = true
+		m.loading = true
+		m.userInput.SetValue(saved.User)
+	}
+
+	return m
 }
 
 func (m CreateUser) Init() tea.Cmd {
+	if m.saved != nil {
+		return tea.Batch(textinput.Blink, checkSession(m.client, *m.saved))
+	}
 	return textinput.Blink
 }
 
+// checkSession asks the server whether a remembered token is still good,
+// refreshing it if it expired.
+func checkSession(client *request.Client, saved config.Session) tea.Cmd {
+	return func() tea.Msg {
+		client.SetSession(saved.User, dto.Session{Token: saved.Token, RefreshToken: saved.RefreshToken})
+		name, err := client.Me()
+		if err == nil && name != saved.User {
+			err = fmt.Errorf("the saved session belongs to %s", name)
+		}
+
+		if current := client.Session(); err == nil && current.Token != saved.Token {
+			saved.Token, saved.RefreshToken = current.Token, current.RefreshToken
+			if err := config.SaveSession(client.URL(), saved); err != nil {
+				log.Println("save session:", err)
+			}
+		}
+		return sessionChecked{session: saved, error: err}
+	}
+}
+
+// fields lists the parts of the form in tab order; only registering asks
+// to confirm the password.
+func (m *CreateUser) fields() []loginField {
+	if m.register {
+		return []loginField{userField, passwordField, confirmField, rememberField}
+	}
+	return []loginField{userField, passwordField, rememberField}
+}
+
+// moveFocus steps through the form, wrapping around.
+func (m *CreateUser) moveFocus(delta int) {
+	fields := m.fields()
+	i := slices.Index(fields, m.focus)
+	m.setFocus(fields[(i+delta+len(fields))%len(fields)])
+}
+
+func (m *CreateUser) setFocus(field loginField) {
+	m.focus = field
+	m.userInput.Blur()
+	m.passwordInput.Blur()
+	m.confirmInput.Blur()
+	switch field {
+	case userField:
+		m.userInput.Focus()
+	case passwordField:
+		m.passwordInput.Focus()
+	case confirmField:
+		m.confirmInput.Focus()
+	}
+}
+
+// submit checks the form and sends it as a login or a registration.
+func (m *CreateUser) submit() tea.Cmd {
+	name, password := m.userInput.Value(), m.passwordInput.Value()
+	if name == "" {
+		m.err = fmt.Errorf("name cannot be empty")
+		return nil
+	}
+	if m.register && password != m.confirmInput.Value() {
+		m.err = fmt.Errorf("passwords don't match")
+		m.setFocus(confirmField)
+		return nil
+	}
+
+	m.err = nil
+	m.loading = true
+	client, register := m.client, m.register
+	return func() tea.Msg {
+		var session dto.Session
+		var err error
+		if register {
+			session, err = client.Register(name, password)
+		} else {
+			session, err = client.Login(name, password)
+		}
+		return createUserStatus{
+			session: session,
+			error:   err,
+		}
+	}
+}
+
 func (m CreateUser) Update(msg tea.Msg) (CreateUser, tea.Cmd) {
 	var cmds []tea.Cmd
 
@@ -64,43 +210,83 @@ func (m CreateUser) Update(msg tea.Msg) (CreateUser, tea.Cmd) {
 			return m, tea.Quit
 		case tea.KeyEnter:
 			if !m.loading {
-				if m.userInput.Value() == "" {
-					m.err = fmt.Errorf("name cannot be empty")
-				} else {
-					m.loading = true
-					cmds = append(cmds, func() tea.Msg {
-						token, err := request.CreateUser(m.userInput.Value(), m.passwordInput.Value())
-						return createUserStatus{
-							token: token,
-							error: err,
-						}
-					})
-				}
+				cmds = append(cmds, m.submit())
 			}
-		case tea.KeyTab:
-			if m.focusOnUser {
-				m.userInput.Blur()
-				m.passwordInput.Focus()
-			} else {
-				m.passwordInput.Blur()
-				m.userInput.Focus()
+		case tea.KeyCtrlR:
+			m.register = !m.register
+			m.err = nil
+			m.confirmInput.SetValue("")
+			if m.focus == confirmField {
+				m.setFocus(passwordField)
+			}
+		case tea.KeyTab, tea.KeyDown:
+			m.moveFocus(1)
+		case tea.KeyShiftTab, tea.KeyUp:
+			m.moveFocus(-1)
+		case tea.KeySpace:
+			if m.focus == rememberField {
+				m.remember = !m.remember
 			}
-			m.focusOnUser = !m.focusOnUser
 		}
 
 		// We handle errors just like any other message
 	case createUserStatus:
 		m.loading = false
-		if msg.error != nil {
+		if errors.Is(msg.error, request.ErrUnknownUser) {
+			m.err = fmt.Errorf("no account is named %s yet, confirm your password to register", m.userInput.Value())
+			m.register = true
+			m.setFocus(confirmField)
+		} else if msg.error != nil {
 			m.err = msg.error
 		} else {
+			session := config.Session{
+				User:         m.userInput.Value(),
+				Token:        msg.session.Token,
+				RefreshToken: msg.session.RefreshToken,
+			}
+			remember := m.remember
+			server := m.client.URL()
 			cmds = append(cmds, func() tea.Msg {
+				var err error
+				if remember {
+					err = config.SaveSession(server, session)
+				} else {
+					err = config.DeleteSession(server)
+				}
+				if err != nil {
+					log.Println("save session:", err)
+				}
 				return signal.UserInfo{
-					Name:  m.userInput.Value(),
-					Token: msg.token,
+					Name:         session.User,
+					Token:        session.Token,
+					RefreshToken: session.RefreshToken,
 				}
 			})
 		}
+
+	case sessionChecked:
+		m.loading = false
+		m.saved = nil
+		if msg.error == nil {
+			return m, func() tea.Msg {
+				return signal.UserInfo{
+					Name:         msg.session.User,
+					Token:        msg.session.Token,
+					RefreshToken: msg.session.RefreshToken,
+				}
+			}
+		}
+
+		m.client.SetCredentials("", "")
+		m.setFocus(passwordField)
+		if request.IsAuthError(msg.error) {
+			m.err = fmt.Errorf("your session has expired, please log in again")
+			if err := config.DeleteSession(m.client.URL()); err != nil {
+				log.Println("delete session:", err)
+			}
+		} else {
+			m.err = fmt.Errorf("could not resume your session: %w", msg.error)
+		}
 	}
 
 	textInput, cmd := m.userInput.Update(msg)
@@ -109,6 +295,9 @@ func (m CreateUser) Update(msg tea.Msg) (CreateUser, tea.Cmd) {
 	passwordInput, cmd := m.passwordInput.Update(msg)
 	cmds = append(cmds, cmd)
 	m.passwordInput = passwordInput
+	confirmInput, cmd := m.confirmInput.Update(msg)
+	cmds = append(cmds, cmd)
+	m.confirmInput = confirmInput
 	return m, tea.Batch(cmds...)
 }
 
@@ -117,25 +306,48 @@ func (m CreateUser) View() string {
 	if m.err != nil {
 		errMessage = errorMsgStyle.Render(m.err.Error())
 	}
-	var input string
-	if m.loading {
-		input = common.Spinner.View()
-	} else {
-		input = m.userInput.View()
+
+	active := lipgloss.NewStyle().Foreground(design.Highlight).Bold(true).Underline(true)
+	inactive := lipgloss.NewStyle().Foreground(design.Subtle)
+	login, register := active.Render("Log in"), inactive.Render("Register")
+	other := "register"
+	if m.register {
+		login, register = inactive.Render("Log in"), active.Render("Register")
+		other = "log in"
 	}
 
-	var passwordInput string
-	if m.loading {
-		passwordInput = common.Spinner.View()
-	} else {
-		passwordInput = m.passwordInput.View()
+	rows := []string{}
+	for _, field := range m.fields() {
+		switch {
+		case m.loading && field != rememberField:
+			rows = append(rows, common.Spinner.View())
+		case field == userField:
+			rows = append(rows, m.userInput.View())
+		case field == passwordField:
+			rows = append(rows, m.passwordInput.View())
+		case field == confirmField:
+			rows = append(rows, m.confirmInput.View())
+		case field == rememberField:
+			box := "[ ]"
+			if m.remember {
+				box = "[x]"
+			}
+			remember := box + " Remember me"
+			if m.focus == rememberField {
+				remember = lipgloss.NewStyle().Foreground(design.Highlight).Render("> " + remember)
+			} else {
+				remember = "  " + remember
+			}
+			rows = append(rows, remember)
+		}
 	}
 
 	return fmt.Sprintf(
-		"What's your name?\n\n%s\n%s\n\n%s\n\n%s",
-		input,
-		passwordInput,
-		"(esc to quit)",
+		"%s  %s\n\n%s\n\n%s\n\n%s",
+		login,
+		register,
+		strings.Join(rows, "\n"),
+		"(tab to move, space to toggle, ctrl+r to "+other+", esc to quit)",
 		errMessage,
 	) + "\n"
 }
@@ -0,0 +1,225 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/e2e"
+	"github.com/onfirebyte/chatt/request"
+	"github.com/onfirebyte/chatt/store"
+)
+
+// keyVerified is the answer to /verify.
+type keyVerified struct {
+	key   string
+	user  string
+	peer  []byte
+	trust bool
+	err   error
+}
+
+// openKeys loads our identity once we know who we are and publishes its
+// public half.
+func (m *Chat) openKeys(user string) tea.Cmd {
+	if !m.options.E2E || m.identity != nil {
+		return nil
+	}
+
+	dir, err := store.AccountDir(m.client.URL(), user)
+	if err != nil {
+		log.Println("open keys:", err)
+		return nil
+	}
+	identity, err := e2e.LoadIdentity(filepath.Join(dir, "identity.key"))
+	if err != nil {
+		log.Println("load identity:", err)
+		return nil
+	}
+	peers, err := e2e.LoadPeers(filepath.Join(dir, "peers.json"))
+	if err != nil {
+		log.Println("load peer keys:", err)
+		return nil
+	}
+	m.identity, m.peers, m.replays = identity, peers, e2e.NewReplays()
+
+	client := m.client
+	return func() tea.Msg {
+		if err := client.PublishKey(identity.Public[:]); err != nil {
+			log.Println("publish key:", err)
+		}
+		return nil
+	}
+}
+
+// dial connects conv. With encryption on, a direct message first looks up
+// the other user's key, so it is known before any message arrives.
+func (m *Chat) dial(conv *conversation) tea.Cmd {
+	m.dials++
+	conv.dial = m.dials
+	key, dial, target := conv.key, conv.dial, conv.target
+	if m.identity == nil || target.IsRoom {
+		return ConnectWS(m.client, key, dial, target)
+	}
+
+	client := m.client
+	return func() tea.Msg {
+		peer, err := client.GetKey(target.Value)
+		if err != nil && !errors.Is(err, request.ErrNoKey) {
+			return chatError{key: key, dial: dial, err: err}
+		}
+
+		res := ConnectWS(client, key, dial, target)()
+		if conn, ok := res.(chatConn); ok {
+			conn.peerKey = peer
+			return conn
+		}
+		return res
+	}
+}
+
+// setPeerKey switches conv to the key the other user has published, or to
+// plaintext when there is none.
+func (m *Chat) setPeerKey(conv *conversation, peer []byte) {
+	wasEncrypted := conv.shared != nil
+	conv.peerKey, conv.shared, conv.keyChanged = nil, nil, false
+	conv.self, conv.replays = m.client.UserName(), m.replays
+	conv.wantsEncryption = m.identity != nil && !conv.target.IsRoom
+	if !conv.wantsEncryption {
+		return
+	}
+
+	user := conv.target.Value
+	if peer == nil {
+		switch {
+		case m.peers.Known(user):
+			// The server may be hiding the key to read along, so this
+			// waits for the user like a changed key does.
+			conv.keyChanged = true
+			conv.local(dto.TypeError, fmt.Sprintf("%s's encryption key is gone from the server; nothing is sent until you run /verify %s trust", user, user))
+		case wasEncrypted:
+			conv.local(dto.TypeError, user+" no longer has an encryption key, messages are not encrypted")
+		}
+		return
+	}
+	key, err := e2e.ParseKey(peer)
+	if err != nil {
+		log.Println("peer key:", err)
+		return
+	}
+
+	changed, err := m.peers.Check(user, key)
+	if err != nil {
+		log.Println("remember peer key:", err)
+	}
+	conv.peerKey = &key
+	conv.shared = m.identity.Shared(key)
+	conv.plaintextConfirmed = false
+	conv.keyChanged = changed
+	if changed {
+		conv.local(dto.TypeError, fmt.Sprintf("%s's key has changed. Compare safety numbers with /verify %s, then run /verify %s trust", user, user, user))
+	}
+}
+
+// VerifyKey looks up the key of user for /verify.
+func VerifyKey(client *request.Client, key string, user string, trust bool) tea.Cmd {
+	return func() tea.Msg {
+		peer, err := client.GetKey(user)
+		return keyVerified{key: key, user: user, peer: peer, trust: trust, err: err}
+	}
+}
+
+// receiveVerify shows the safety number of the conversation with msg.user
+// and, when asked to, trusts the key it belongs to.
+func (m *Chat) receiveVerify(msg keyVerified) {
+	conv := m.find(msg.key)
+	if msg.key == m.lobby.key {
+		conv = m.lobby
+	}
+	if conv == nil {
+		return
+	}
+	if errors.Is(msg.err, request.ErrNoKey) {
+		m.forgetKey(conv, msg.user, msg.trust)
+		return
+	}
+	if msg.err != nil {
+		conv.local(dto.TypeError, "verify: "+msg.err.Error())
+		return
+	}
+	peer, err := e2e.ParseKey(msg.peer)
+	if err != nil {
+		conv.local(dto.TypeError, "verify: "+err.Error())
+		return
+	}
+
+	conv.local(dto.TypeSystem, "safety number with "+msg.user+": "+e2e.SafetyNumber(m.identity.Public, peer))
+	conv.local(dto.TypeSystem, "it matches what "+msg.user+" sees only if nobody can read your messages in between")
+
+	changed, err := m.peers.Check(msg.user, peer)
+	if err != nil {
+		log.Println("remember peer key:", err)
+	}
+	if !changed {
+		return
+	}
+	if !msg.trust {
+		conv.local(dto.TypeError, fmt.Sprintf("this is not the key you had before; once the numbers match, run /verify %s trust", msg.user))
+		return
+	}
+
+	if err := m.peers.Trust(msg.user, peer); err != nil {
+		conv.local(dto.TypeError, "verify: "+err.Error())
+		return
+	}
+	conv.local(dto.TypeSystem, "trusting the new key of "+msg.user)
+	for _, c := range m.conversations {
+		if !c.target.IsRoom && c.target.Value == msg.user && c.peerKey != nil && *c.peerKey == peer {
+			c.keyChanged = false
+		}
+	}
+}
+
+// forgetKey handles /verify for a user without a key. A key we remember for
+// them is only dropped, allowing plaintext again, when asked to trust.
+func (m *Chat) forgetKey(conv *conversation, user string, trust bool) {
+	if !m.peers.Known(user) {
+		conv.local(dto.TypeError, user+" has no encryption key, messages with them are not encrypted")
+		return
+	}
+	if !trust {
+		conv.local(dto.TypeError, fmt.Sprintf("%s had an encryption key, but the server has none now; to write to them unencrypted, run /verify %s trust", user, user))
+		return
+	}
+
+	if err := m.peers.Forget(user); err != nil {
+		conv.local(dto.TypeError, "verify: "+err.Error())
+		return
+	}
+	conv.local(dto.TypeSystem, "forgot the key of "+user+", messages with them are not encrypted")
+	for _, c := range m.conversations {
+		if !c.target.IsRoom && c.target.Value == user && c.peerKey == nil {
+			c.keyChanged = false
+		}
+	}
+}
+
+func runVerify(m *Chat, conv *conversation, args string) (tea.Cmd, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 || len(fields) > 2 || (len(fields) == 2 && fields[1] != "trust") {
+		return nil, errUsage
+	}
+	if m.identity == nil {
+		return nil, errors.New("encryption is off, start chatt with --e2e to turn it on")
+	}
+
+	user := strings.TrimPrefix(fields[0], "@")
+	if user == m.client.UserName() {
+		return nil, errors.New("safety numbers are between you and someone else")
+	}
+	return VerifyKey(m.client, conv.key, user, len(fields) == 2), nil
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	lip "github.com/charmbracelet/lipgloss"
+	"github.com/onfirebyte/chatt/design"
+)
+
+var matchStyle = lip.NewStyle().Foreground(design.Highlight).Bold(true).Underline(true)
+
+// listFilter is the filter opened with / in the Users and Rooms tabs. The
+// filter stays applied after enter until esc clears it.
+type listFilter struct {
+	input  textinput.Model
+	typing bool
+}
+
+func newListFilter() listFilter {
+	ti := textinput.New()
+	ti.Prompt = "/"
+	ti.Placeholder = "filter"
+	ti.CharLimit = 32
+	ti.Width = LeftTabWidth - 8
+	ti.Blur()
+	return listFilter{input: ti}
+}
+
+func (f *listFilter) query() string {
+	return f.input.Value()
+}
+
+// shown reports whether the filter takes a row of the tab.
+func (f *listFilter) shown() bool {
+	return f.typing || f.input.Value() != ""
+}
+
+func (f *listFilter) start() tea.Cmd {
+	f.typing = true
+	return f.input.Focus()
+}
+
+func (f *listFilter) stop() {
+	f.typing = false
+	f.input.Blur()
+}
+
+func (f *listFilter) clear() {
+	f.stop()
+	f.input.SetValue("")
+}
+
+// update handles a key while the filter is being typed. It reports false for
+// keys the list should handle itself: moving the cursor and enter, which
+// also stops typing.
+func (f *listFilter) update(msg tea.KeyMsg) (bool, tea.Cmd) {
+	if !f.typing {
+		return false, nil
+	}
+
+	switch msg.String() {
+	case "up", "down":
+		return false, nil
+	case "enter":
+		f.stop()
+		return false, nil
+	case "esc":
+		f.clear()
+		return true, nil
+	}
+
+	var cmd tea.Cmd
+	f.input, cmd = f.input.Update(msg)
+	return true, cmd
+}
+
+// fuzzyMatch reports whether the letters of pattern appear in s in order,
+// ignoring case, and returns the rune positions they matched.
+func fuzzyMatch(s string, pattern string) ([]int, bool) {
+	want := []rune(strings.ToLower(pattern))
+	if len(want) == 0 {
+		return nil, true
+	}
+
+	pos := []int{}
+	for i, r := range []rune(s) {
+		if unicode.ToLower(r) == want[len(pos)] {
+			pos = append(pos, i)
+			if len(pos) == len(want) {
+				return pos, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// filterIndexes returns the indexes of the names matching query, in order.
+func filterIndexes(names []string, query string) []int {
+	res := make([]int, 0, len(names))
+	for i, v := range names {
+		if _, ok := fuzzyMatch(v, query); ok {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+// keepSelection returns the cursor and scroll offset that keep the entry at
+// pos of a filtered list of n entries under the cursor. A pos of -1, for an
+// entry that was filtered out, moves the cursor to the top.
+func keepSelection(pos int, offset int, rows int, n int) (int, int) {
+	if pos < 0 {
+		return 0, 0
+	}
+
+	if pos < offset {
+		offset = pos
+	}
+	if pos >= offset+rows {
+		offset = pos - rows + 1
+	}
+	offset = max(min(offset, n-rows), 0)
+	return pos - offset, offset
+}
+
+// highlightMatches renders s in base with the runes matching query
+// highlighted.
+func highlightMatches(s string, query string, base lip.Style) string {
+	pos, ok := fuzzyMatch(s, query)
+	if !ok || len(pos) == 0 {
+		return base.Render(s)
+	}
+
+	var b strings.Builder
+	runes := []rune(s)
+	next := 0
+	for i, r := range runes {
+		if next < len(pos) && pos[next] == i {
+			b.WriteString(matchStyle.Copy().Inherit(base).Render(string(r)))
+			next++
+		} else {
+			b.WriteString(base.Render(string(r)))
+		}
+	}
+	return b.String()
+}
@@ -5,7 +5,6 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	lip "github.com/charmbracelet/lipgloss"
-	"github.com/onfirebyte/chatt/common"
 	"github.com/onfirebyte/chatt/request"
 	"github.com/onfirebyte/chatt/signal"
 )
@@ -13,6 +12,8 @@ import (
 type Home struct {
 	inited bool
 
+	client *request.Client
+
 	selectedTab selectedTab
 
 	userTab UserListTab
@@ -30,11 +31,12 @@ const (
 
 var LeftTabWidth = 32
 
-func NewHomeModel() Home {
-	chat := NewChatModel("Chat")
+func NewHomeModel(client *request.Client, options Options) Home {
+	chat := NewChatModel("Chat", client, options)
 	return Home{
-		userTab:     NewUserListTabModel("Users", request.GetAllUsers),
-		roomTab:     NewRoomListTabModel("Rooms", request.GetAllRooms),
+		client:      client,
+		userTab:     NewUserListTabModel("Users", client),
+		roomTab:     NewRoomListTabModel("Rooms", client),
 		chatTab:     &chat,
 		selectedTab: chatTab,
 	}
@@ -84,7 +86,14 @@ func (m Home) Update(msg tea.Msg) (Home, tea.Cmd) {
 
 	case tea.KeyMsg:
 		switch msg.String() {
+		case "ctrl+o":
+			// Works from any tab, with or without a conversation open.
+			return m, logout(m.client)
 		case "tab":
+			// The chat pane uses tab to complete mentions and commands.
+			if m.selectedTab == chatTab && m.chatTab.completing() {
+				break
+			}
 			m.selectedTab = (m.selectedTab + 1) % 3
 
 			m.userTab, cmd = m.userTab.Update(signal.HomeTabSelected(m.selectedTab == userTab))
@@ -111,7 +120,7 @@ func (m Home) Update(msg tea.Msg) (Home, tea.Cmd) {
 }
 
 func (m Home) View() string {
-	title := lip.NewStyle().Foreground(lip.Color("205")).Render(fmt.Sprintf("Welcome %s", common.UserName))
+	title := lip.NewStyle().Foreground(lip.Color("205")).Render(fmt.Sprintf("Welcome %s", m.client.UserName()))
 	leftTab := lip.JoinVertical(lip.Bottom,
 		m.userTab.View(),
 		m.roomTab.View())
@@ -0,0 +1,164 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+
+	lip "github.com/charmbracelet/lipgloss"
+	"github.com/onfirebyte/chatt/design"
+)
+
+var (
+	inlineCodeStyle = lip.NewStyle().Foreground(design.Code).Background(design.CodeBackground)
+	codeBlockStyle  = lip.NewStyle().Foreground(design.Code).Background(design.CodeBackground)
+	quoteBarStyle   = lip.NewStyle().Foreground(design.Subtle)
+	quoteStyle      = lip.NewStyle().Foreground(lip.Color("246")).Italic(true)
+	bulletStyle     = lip.NewStyle().Foreground(design.Highlight)
+
+	orderedItem = regexp.MustCompile(`^(\s*)(\d+)[.)]\s+(.*)$`)
+	bulletItem  = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+)
+
+// renderMarkdown renders the subset of markdown people use in chat: bold,
+// italics, inline code, fenced code blocks, blockquotes and lists. Anything
+// else is left as typed. Text matching mark, if set, is highlighted.
+func renderMarkdown(src string, mark *regexp.Regexp) string {
+	lines := strings.Split(src, "\n")
+	out := make([]string, 0, len(lines))
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if fence, ok := strings.CutPrefix(strings.TrimSpace(line), "```"); ok && !strings.Contains(fence, "`") {
+			end := i + 1
+			for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), "```") {
+				end++
+			}
+			out = append(out, renderCodeBlock(lines[i+1:end], mark)...)
+			i = end
+			continue
+		}
+
+		if quoted, ok := strings.CutPrefix(line, ">"); ok {
+			quoted = strings.TrimPrefix(quoted, " ")
+			out = append(out, quoteBarStyle.Render("│ ")+renderInline(quoted, quoteStyle, mark))
+			continue
+		}
+
+		if m := bulletItem.FindStringSubmatch(line); m != nil {
+			out = append(out, m[1]+bulletStyle.Render("•")+" "+renderInline(m[2], lip.NewStyle(), mark))
+			continue
+		}
+
+		if m := orderedItem.FindStringSubmatch(line); m != nil {
+			out = append(out, m[1]+bulletStyle.Render(m[2]+".")+" "+renderInline(m[3], lip.NewStyle(), mark))
+			continue
+		}
+
+		out = append(out, renderInline(line, lip.NewStyle(), mark))
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// renderCodeBlock pads every line to the same width so the background reads
+// as one block.
+func renderCodeBlock(lines []string, mark *regexp.Regexp) []string {
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+
+	width := 0
+	for _, v := range lines {
+		width = max(width, lip.Width(v))
+	}
+
+	res := make([]string, len(lines))
+	for i, v := range lines {
+		res[i] = codeBlockStyle.Render(" ") + markText(v, codeBlockStyle, mark) + codeBlockStyle.Render(strings.Repeat(" ", width-lip.Width(v)+1))
+	}
+	return res
+}
+
+// renderInline applies emphasis and inline code within a single line.
+// Delimiters without a closing partner are printed as they are.
+func renderInline(s string, base lip.Style, mark *regexp.Regexp) string {
+	var res strings.Builder
+	var buf strings.Builder
+	bold, italic := false, false
+
+	flush := func() {
+		if buf.Len() == 0 {
+			return
+		}
+		res.WriteString(markText(buf.String(), base.Copy().Bold(bold).Italic(italic || base.GetItalic()), mark))
+		buf.Reset()
+	}
+
+	for i := 0; i < len(s); i++ {
+		rest := s[i:]
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.ContainsRune("\\`*_", rune(rest[1])):
+			buf.WriteByte(rest[1])
+			i++
+
+		case rest[0] == '`':
+			end := strings.IndexByte(rest[1:], '`')
+			if end < 0 {
+				buf.WriteByte('`')
+				continue
+			}
+			flush()
+			res.WriteString(markText(rest[1:end+1], inlineCodeStyle, mark))
+			i += end + 1
+
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if !bold && !strings.Contains(rest[2:], rest[:2]) {
+				buf.WriteString(rest[:2])
+				i++
+				continue
+			}
+			flush()
+			bold = !bold
+			i++
+
+		case rest[0] == '*' || (rest[0] == '_' && (i == 0 || s[i-1] == ' ' || italic)):
+			if !italic && !strings.ContainsRune(rest[1:], rune(rest[0])) {
+				buf.WriteByte(rest[0])
+				continue
+			}
+			flush()
+			italic = !italic
+
+		default:
+			buf.WriteByte(rest[0])
+		}
+	}
+	flush()
+
+	return res.String()
+}
+
+// markText renders s in style with the parts matching mark highlighted.
+func markText(s string, style lip.Style, mark *regexp.Regexp) string {
+	if mark == nil {
+		return style.Render(s)
+	}
+
+	var res strings.Builder
+	last := 0
+	for _, loc := range mark.FindAllStringIndex(s, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		if loc[0] > last {
+			res.WriteString(style.Render(s[last:loc[0]]))
+		}
+		res.WriteString(searchMatchStyle.Render(s[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	if last < len(s) {
+		res.WriteString(style.Render(s[last:]))
+	}
+	return res.String()
+}
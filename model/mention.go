@@ -0,0 +1,122 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	lip "github.com/charmbracelet/lipgloss"
+	"github.com/onfirebyte/chatt/design"
+	"github.com/onfirebyte/chatt/dto"
+)
+
+var mentionCountStyle = lip.NewStyle().Foreground(design.Mention).Bold(true)
+
+// mentionQuery returns the partial name after a trailing @ in text and the
+// offset of the name.
+func mentionQuery(text string) (string, int, bool) {
+	at := strings.LastIndexByte(text, '@')
+	if at < 0 {
+		return "", 0, false
+	}
+	if at > 0 {
+		prev, _ := utf8.DecodeLastRuneInString(text[:at])
+		if !unicode.IsSpace(prev) && !unicode.IsPunct(prev) {
+			return "", 0, false
+		}
+	}
+	query := text[at+1:]
+	if strings.ContainsFunc(query, unicode.IsSpace) {
+		return "", 0, false
+	}
+	return query, at + 1, true
+}
+
+// mentionCandidates lists who can be mentioned in conv: everyone the user
+// list knows about plus whoever has spoken or joined here.
+func (m *Chat) mentionCandidates(conv *conversation) []string {
+	seen := map[string]bool{m.client.UserName(): true}
+	res := []string{}
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			res = append(res, name)
+		}
+	}
+
+	for _, v := range m.users {
+		add(v)
+	}
+	if conv != nil {
+		for _, v := range conv.data {
+			if v.Kind == dto.TypeMessage || v.Kind == dto.TypeJoin {
+				add(v.User)
+			}
+		}
+	}
+	return res
+}
+
+// mentionSuggestions completes the @name being typed at the end of text.
+func (m *Chat) mentionSuggestions(conv *conversation, text string) (int, []suggestion) {
+	query, start, ok := mentionQuery(text)
+	if !ok {
+		return 0, nil
+	}
+
+	names := matchPrefix(m.mentionCandidates(conv), query)
+	res := make([]suggestion, len(names))
+	for i, v := range names {
+		res[i] = suggestion{value: v, label: "@" + v}
+	}
+	return start, res
+}
+
+// matchPrefix returns the sorted entries of list that start with prefix,
+// ignoring case.
+func matchPrefix(list []string, prefix string) []string {
+	prefix = strings.ToLower(prefix)
+	res := []string{}
+	for _, v := range list {
+		if strings.HasPrefix(strings.ToLower(v), prefix) {
+			res = append(res, v)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// mentions reports whether text mentions name as a whole word, ignoring
+// case like completion does. Dots right after the name end the sentence
+// rather than the name, unless more of a name follows them.
+func mentions(text string, name string) bool {
+	if name == "" {
+		return false
+	}
+	text = strings.ToLower(text)
+	needle := "@" + strings.ToLower(name)
+	for i := 0; ; {
+		j := strings.Index(text[i:], needle)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(needle)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(strings.TrimLeft(text[end:], "."))
+		if !nameRune(before) && !nameRune(after) {
+			return true
+		}
+		i = end
+	}
+}
+
+func nameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
+}
+
+// mentionsUs reports whether v is someone else's message mentioning us.
+func (m *Chat) mentionsUs(v chatMessage) bool {
+	self := m.client.UserName()
+	return v.Kind == dto.TypeMessage && !v.Deleted && v.User != self && mentions(v.Data, self)
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"github.com/onfirebyte/chatt/design"
+	"github.com/onfirebyte/chatt/store"
+)
+
+// Options are the user preferences that shape the chat pane.
+type Options struct {
+	// Markdown renders message formatting; raw text is shown when false.
+	Markdown bool
+	// ComposerHeight is how many rows the message composer grows to before
+	// it scrolls.
+	ComposerHeight int
+	// StoreLimit is how many messages per conversation are kept on disk.
+	// Zero turns the local store off.
+	StoreLimit int
+	// E2E encrypts direct messages with users who published a key.
+	E2E bool
+	// StoreEncrypted keeps encrypted direct messages in the local store
+	// once decrypted.
+	StoreEncrypted bool
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Markdown:       true,
+		ComposerHeight: design.ComposerHeight,
+		StoreLimit:     store.DefaultLimit,
+	}
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	lip "github.com/charmbracelet/lipgloss"
+	"github.com/onfirebyte/chatt/design"
+	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/request"
+)
+
+const (
+	// awayAfter is how long without a key press before we report away.
+	awayAfter = 5 * time.Minute
+	idleCheck = 30 * time.Second
+)
+
+// Messages of the control connection, which UserListTab keeps open for
+// presence updates.
+type (
+	presenceConn struct {
+		connection *request.Conn
+	}
+	presenceReceived struct {
+		connection *request.Conn
+		presence   dto.Presence
+	}
+	presenceError struct {
+		connection *request.Conn
+		err        error
+	}
+	presenceReconnect struct{}
+	presenceIdle      struct{}
+)
+
+func connectEvents(client *request.Client) tea.Cmd {
+	return func() tea.Msg {
+		c, err := client.DialEvents()
+		if err != nil {
+			return presenceError{err: err}
+		}
+		return presenceConn{connection: c}
+	}
+}
+
+func readPresence(c *request.Conn) tea.Cmd {
+	return func() tea.Msg {
+		for {
+			env, err := c.Receive()
+			if err != nil {
+				c.Close()
+				return presenceError{connection: c, err: err}
+			}
+			if env.Type != dto.TypePresence {
+				continue
+			}
+
+			var p dto.Presence
+			if err := env.Decode(&p); err == nil {
+				return presenceReceived{connection: c, presence: p}
+			}
+		}
+	}
+}
+
+func checkIdle() tea.Cmd {
+	return tea.Tick(idleCheck, func(time.Time) tea.Msg {
+		return presenceIdle{}
+	})
+}
+
+// presenceRank orders online users first, then away, then offline.
+func presenceRank(status dto.PresenceStatus) int {
+	switch status {
+	case dto.Online:
+		return 0
+	case dto.Away:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func presenceDot(status dto.PresenceStatus) string {
+	switch status {
+	case dto.Online:
+		return lip.NewStyle().Foreground(design.Special).Render("●")
+	case dto.Away:
+		return lip.NewStyle().Foreground(design.Away).Render("●")
+	default:
+		return lip.NewStyle().Foreground(design.Subtle).Render("○")
+	}
+}
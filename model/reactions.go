@@ -0,0 +1,211 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	lip "github.com/charmbracelet/lipgloss"
+	"github.com/onfirebyte/chatt/design"
+	"github.com/onfirebyte/chatt/dto"
+)
+
+// quickReactions are offered in the picker before anything is typed.
+var quickReactions = []string{"👍", "❤️", "😂", "🎉", "😮", "😢", "👀", "🚀"}
+
+var shortcodes = map[string]string{
+	"+1":               "👍",
+	"thumbsup":         "👍",
+	"-1":               "👎",
+	"thumbsdown":       "👎",
+	"heart":            "❤️",
+	"joy":              "😂",
+	"laughing":         "😆",
+	"smile":            "😄",
+	"tada":             "🎉",
+	"party":            "🥳",
+	"open_mouth":       "😮",
+	"cry":              "😢",
+	"eyes":             "👀",
+	"rocket":           "🚀",
+	"fire":             "🔥",
+	"100":              "💯",
+	"pray":             "🙏",
+	"clap":             "👏",
+	"thinking":         "🤔",
+	"ok_hand":          "👌",
+	"wave":             "👋",
+	"white_check_mark": "✅",
+	"check":            "✅",
+	"x":                "❌",
+	"sparkles":         "✨",
+	"skull":            "💀",
+	"see_no_evil":      "🙈",
+	"bug":              "🐛",
+	"coffee":           "☕",
+	"ship":             "🚢",
+}
+
+var (
+	reactionStyle    = lip.NewStyle().Foreground(lip.Color("246"))
+	ownReactionStyle = lip.NewStyle().Foreground(design.Highlight).Bold(true)
+)
+
+// reactionPicker chooses an emoji for the message with ID target in the
+// conversation with key, either from quickReactions or by typing a
+// shortcode.
+type reactionPicker struct {
+	key    string
+	target string
+	idx    int
+	input  textinput.Model
+}
+
+func newReactionPicker(key string, target string) *reactionPicker {
+	ti := textinput.New()
+	ti.Prompt = ":"
+	ti.Placeholder = "shortcode"
+	ti.CharLimit = 32
+	ti.Width = 16
+	ti.Focus()
+
+	return &reactionPicker{
+		key:    key,
+		target: target,
+		input:  ti,
+	}
+}
+
+// matches returns the shortcodes starting with what was typed, sorted.
+func (p *reactionPicker) matches() []string {
+	typed := strings.Trim(p.input.Value(), ":")
+	if typed == "" {
+		return nil
+	}
+
+	res := []string{}
+	for code := range shortcodes {
+		if strings.HasPrefix(code, typed) {
+			res = append(res, code)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// choice is the emoji enter would send.
+func (p *reactionPicker) choice() (string, bool) {
+	if p.input.Value() == "" {
+		return quickReactions[p.idx], true
+	}
+
+	matches := p.matches()
+	if len(matches) == 0 {
+		return "", false
+	}
+	return shortcodes[matches[min(p.idx, len(matches)-1)]], true
+}
+
+func (p *reactionPicker) Update(msg tea.KeyMsg) tea.Cmd {
+	options := len(quickReactions)
+	if p.input.Value() != "" {
+		options = len(p.matches())
+	}
+
+	switch msg.String() {
+	case "left":
+		p.idx = max(p.idx-1, 0)
+		return nil
+	case "right":
+		p.idx = min(p.idx+1, max(options-1, 0))
+		return nil
+	}
+
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	p.idx = 0
+	return cmd
+}
+
+func (p *reactionPicker) View(width int) string {
+	items := []string{}
+	if p.input.Value() == "" {
+		items = quickReactions
+	} else {
+		for _, code := range p.matches() {
+			items = append(items, shortcodes[code]+" "+code)
+		}
+	}
+
+	for i, v := range items {
+		if i == p.idx {
+			items[i] = ownReactionStyle.Render("[" + v + "]")
+		} else {
+			items[i] = " " + v + " "
+		}
+	}
+	if len(items) == 0 {
+		items = []string{eventStyle.Render("no match")}
+	}
+
+	return lip.NewStyle().MaxWidth(width).Render("react " + p.input.View() + " " + strings.Join(items, ""))
+}
+
+// renderReactions renders the reaction counts of v in one line, with the
+// ones self has given highlighted.
+func renderReactions(v chatMessage, self string) string {
+	emojis := make([]string, 0, len(v.Reactions))
+	for emoji := range v.Reactions {
+		emojis = append(emojis, emoji)
+	}
+	sort.Slice(emojis, func(i, j int) bool {
+		a, b := len(v.Reactions[emojis[i]]), len(v.Reactions[emojis[j]])
+		if a != b {
+			return a > b
+		}
+		return emojis[i] < emojis[j]
+	})
+
+	chips := make([]string, len(emojis))
+	for i, emoji := range emojis {
+		users := v.Reactions[emoji]
+		style := reactionStyle
+		for _, u := range users {
+			if u == self {
+				style = ownReactionStyle
+			}
+		}
+		chips[i] = style.Render(fmt.Sprintf("%s %d", emoji, len(users)))
+	}
+	return " " + strings.Join(chips, "  ")
+}
+
+// openPicker starts reacting to the selected message of conv.
+func (m *Chat) openPicker(conv *conversation) bool {
+	v, idx := m.selectedMessage(conv)
+	if idx < 0 || conv.connection == nil || conv.editing != "" {
+		return false
+	}
+	m.picker = newReactionPicker(conv.key, v.ID)
+	return true
+}
+
+// updatePicker handles a key while the picker is open.
+func (m *Chat) updatePicker(conv *conversation, msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		m.picker = nil
+		return nil
+	case "enter":
+		emoji, ok := m.picker.choice()
+		picker := m.picker
+		m.picker = nil
+		if !ok || conv == nil || conv.key != picker.key {
+			return nil
+		}
+		return conv.send(dto.TypeReact, dto.Reaction{ID: picker.target, Emoji: emoji})
+	}
+	return m.picker.Update(msg)
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"math/rand"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	reconnectBase = 500 * time.Millisecond
+	reconnectMax  = 30 * time.Second
+)
+
+type chatReconnect struct {
+	key string
+}
+
+// reconnectDelay is an exponential backoff with jitter: each attempt waits
+// somewhere between half and all of base*2^(attempt-1), capped at
+// reconnectMax, so clients dropped together don't all come back at once.
+func reconnectDelay(attempt int) time.Duration {
+	d := reconnectMax
+	if attempt < 16 {
+		d = min(reconnectBase<<(attempt-1), reconnectMax)
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func scheduleReconnect(key string, attempt int) tea.Cmd {
+	return tea.Tick(reconnectDelay(attempt), func(time.Time) tea.Msg {
+		return chatReconnect{key: key}
+	})
+}
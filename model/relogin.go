@@ -0,0 +1,145 @@
+package model
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	lip "github.com/charmbracelet/lipgloss"
+	"github.com/onfirebyte/chatt/common"
+	"github.com/onfirebyte/chatt/config"
+	"github.com/onfirebyte/chatt/design"
+	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/request"
+	"github.com/onfirebyte/chatt/signal"
+)
+
+// Relogin asks for the password again once the session expired. It is shown
+// over Home, which keeps running underneath, and the requests that failed
+// meanwhile go through once it succeeds.
+type Relogin struct {
+	client        *request.Client
+	passwordInput textinput.Model
+	err           error
+	loading       bool
+}
+
+type reloginStatus struct {
+	session dto.Session
+	error   error
+}
+
+func NewReloginModel(client *request.Client) Relogin {
+	passwordInput := textinput.New()
+	passwordInput.Placeholder = "Password"
+	passwordInput.CharLimit = 156
+	passwordInput.Width = 20
+	passwordInput.EchoMode = textinput.EchoPassword
+	passwordInput.EchoCharacter = '•'
+	passwordInput.Focus()
+
+	return Relogin{
+		client:        client,
+		passwordInput: passwordInput,
+	}
+}
+
+// WaitExpired turns the client noticing an expired session into a
+// signal.SessionExpired. It has to be issued again after each one.
+func WaitExpired(client *request.Client) tea.Cmd {
+	return func() tea.Msg {
+		<-client.Expired()
+		return signal.SessionExpired{}
+	}
+}
+
+// logout revokes the session on the server, forgets the remembered one and
+// goes back to the login screen.
+func logout(client *request.Client) tea.Cmd {
+	return func() tea.Msg {
+		if err := client.Logout(); err != nil {
+			log.Println("logout:", err)
+		}
+		if err := config.DeleteSession(client.URL()); err != nil {
+			log.Println("delete session:", err)
+		}
+		return signal.Logout{}
+	}
+}
+
+func (m Relogin) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m Relogin) Update(msg tea.Msg) (Relogin, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyEsc:
+			return m, logout(m.client)
+		case tea.KeyEnter:
+			if m.loading {
+				return m, nil
+			}
+			m.loading = true
+			m.err = nil
+			client, password := m.client, m.passwordInput.Value()
+			return m, func() tea.Msg {
+				session, err := client.Login(client.UserName(), password)
+				return reloginStatus{session: session, error: err}
+			}
+		}
+
+	case reloginStatus:
+		m.loading = false
+		if msg.error != nil {
+			m.err = msg.error
+			m.passwordInput.SetValue("")
+			return m, nil
+		}
+
+		name := m.client.UserName()
+		m.client.SetSession(name, msg.session)
+		server := m.client.URL()
+		return m, func() tea.Msg {
+			// Keep a remembered session working for the next launch.
+			if saved, ok, err := config.LoadSession(server); err == nil && ok && saved.User == name {
+				saved.Token, saved.RefreshToken = msg.session.Token, msg.session.RefreshToken
+				if err := config.SaveSession(server, saved); err != nil {
+					log.Println("save session:", err)
+				}
+			}
+			return signal.Reauthenticated{}
+		}
+	}
+
+	m.passwordInput, cmd = m.passwordInput.Update(msg)
+	return m, cmd
+}
+
+func (m Relogin) View() string {
+	input := m.passwordInput.View()
+	if m.loading {
+		input = common.Spinner.View()
+	}
+
+	var errMessage string
+	if m.err != nil {
+		errMessage = "\n\n" + design.ErrorText.Render(m.err.Error())
+	}
+
+	return lip.NewStyle().
+		Border(lip.RoundedBorder(), true).
+		BorderForeground(design.Away).
+		Padding(1, 2).
+		Render(fmt.Sprintf(
+			"Your session expired.\nEnter the password of %s to carry on.\n\n%s\n\n%s%s",
+			m.client.UserName(),
+			input,
+			lip.NewStyle().Foreground(design.Subtle).Render("(enter to log in, esc to log out)"),
+			errMessage,
+		))
+}
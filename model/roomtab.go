@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -9,6 +10,7 @@ import (
 	"github.com/onfirebyte/chatt/common"
 	"github.com/onfirebyte/chatt/design"
 	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/request"
 	"github.com/onfirebyte/chatt/signal"
 )
 
@@ -28,12 +30,17 @@ type RoomListTab struct {
 
 	offset int
 
-	data      []dto.Room
-	error     error
-	fetchFunc func() ([]dto.Room, error)
+	data   []dto.Room
+	error  error
+	client *request.Client
+
+	// view holds the indexes of data that pass the filter; idx and offset
+	// point into it.
+	filter listFilter
+	view   []int
 }
 
-func NewRoomListTabModel(name string, fetchFunc func() ([]dto.Room, error)) RoomListTab {
+func NewRoomListTabModel(name string, client *request.Client) RoomListTab {
 	ti := textinput.New()
 	ti.Placeholder = "Create a room..."
 	ti.Blur()
@@ -48,27 +55,61 @@ func NewRoomListTabModel(name string, fetchFunc func() ([]dto.Room, error)) Room
 
 	return RoomListTab{
 		title:             name,
-		fetchFunc:         fetchFunc,
+		client:            client,
 		textInput:         ti,
 		roomPasswordInput: pi,
+		filter:            newListFilter(),
 	}
 }
 
 func (m RoomListTab) Init() tea.Cmd {
-	return func() tea.Msg {
-		if m.fetchFunc == nil {
-			return RoomListResult{
-				Value: nil,
-				Err:   nil,
-			}
-		}
+	return m.fetch
+}
 
-		res, err := m.fetchFunc()
+func (m RoomListTab) fetch() tea.Msg {
+	if m.client == nil {
 		return RoomListResult{
-			Value: res,
-			Err:   err,
+			Value: nil,
+			Err:   nil,
 		}
 	}
+
+	res, err := m.client.GetAllRooms()
+	return RoomListResult{
+		Value: res,
+		Err:   err,
+	}
+}
+
+// rows is how many rooms fit in the tab.
+func (m *RoomListTab) rows() int {
+	rows := m.height - 4
+	if m.filter.shown() {
+		rows--
+	}
+	return max(rows, 1)
+}
+
+// selected returns the room under the cursor.
+func (m *RoomListTab) selected() (dto.Room, bool) {
+	if i := m.idx + m.offset; i >= 0 && i < len(m.view) {
+		return m.data[m.view[i]], true
+	}
+	return dto.Room{}, false
+}
+
+// refresh rebuilds the filtered view after the data or the filter changed,
+// keeping the cursor on the same room.
+func (m *RoomListTab) refresh(selected string) {
+	names := make([]string, len(m.data))
+	for i, v := range m.data {
+		names[i] = v.Name
+	}
+	m.view = filterIndexes(names, m.filter.query())
+	pos := slices.IndexFunc(m.view, func(i int) bool {
+		return names[i] == selected
+	})
+	m.idx, m.offset = keepSelection(pos, m.offset, m.rows(), len(m.view))
 }
 
 func (m RoomListTab) Update(msg tea.Msg) (RoomListTab, tea.Cmd) {
@@ -88,25 +129,33 @@ func (m RoomListTab) Update(msg tea.Msg) (RoomListTab, tea.Cmd) {
 			m.inputMode = false
 			m.textInput.Blur()
 			m.textInput.SetValue("")
+			m.filter.stop()
 		}
 	case tea.KeyMsg:
+		selected, _ := m.selected()
+		if m.focus {
+			if ok, cmd := m.filter.update(msg); ok {
+				m.refresh(selected.Name)
+				return m, cmd
+			}
+		}
 
 		switch msg.String() {
+		case "/":
+			if m.focus && !m.inputMode && !m.roomPasswordInput.Focused() {
+				cmd := m.filter.start()
+				m.refresh(selected.Name)
+				return m, cmd
+			}
 		case "r":
-			if m.focus && m.fetchFunc != nil && !m.inputMode {
-				return m, func() tea.Msg {
-					res, err := m.fetchFunc()
-					return RoomListResult{
-						Value: res,
-						Err:   err,
-					}
-				}
+			if m.focus && m.client != nil && !m.inputMode {
+				return m, m.fetch
 			}
 		case "down":
 			if m.focus {
-				if m.idx < min(len(m.data)-1, m.height-5) {
+				if m.idx < min(len(m.view)-1, m.rows()-1) {
 					m.idx++
-				} else if m.idx+m.offset < len(m.data)-1 {
+				} else if m.idx+m.offset < len(m.view)-1 {
 					m.offset++
 				}
 			}
@@ -132,6 +181,9 @@ func (m RoomListTab) Update(msg tea.Msg) (RoomListTab, tea.Cmd) {
 			} else if m.roomPasswordInput.Focused() {
 				m.roomPasswordInput.Blur()
 				m.roomPasswordInput.SetValue("")
+			} else if m.focus && m.filter.shown() {
+				m.filter.clear()
+				m.refresh(selected.Name)
 			}
 		case "enter":
 			if !m.focus {
@@ -145,7 +197,10 @@ func (m RoomListTab) Update(msg tea.Msg) (RoomListTab, tea.Cmd) {
 				m.textInput.Blur()
 				m.textInput.SetValue("")
 			} else {
-				data = m.data[m.idx+m.offset]
+				var ok bool
+				if data, ok = m.selected(); !ok {
+					break
+				}
 				roomName = data.Name
 			}
 			if !data.Lock {
@@ -178,20 +233,16 @@ func (m RoomListTab) Update(msg tea.Msg) (RoomListTab, tea.Cmd) {
 
 	case RoomListResult:
 
+		selected, _ := m.selected()
 		m.loading = false
 		m.data = msg.Value
 		m.error = msg.Err
+		m.refresh(selected.Name)
 
 	case signal.Refetch:
-		if msg == "all" && m.fetchFunc != nil {
+		if msg == "all" && m.client != nil {
 			m.loading = true
-			cmds = append(cmds, func() tea.Msg {
-				res, err := m.fetchFunc()
-				return RoomListResult{
-					Value: res,
-					Err:   err,
-				}
-			})
+			cmds = append(cmds, m.fetch)
 		}
 	}
 
@@ -228,21 +279,30 @@ func (m RoomListTab) View() string {
 	}
 
 	items[0] = design.ListHeader.Width(m.width - 4).Render(title)
-	maxLen := min(len(m.data), m.height-4)
+	maxLen := min(len(m.view)-m.offset, m.rows())
 	if m.error != nil {
 		items[1] = design.ErrorText.Render(m.error.Error())
 	} else {
 		for i := 0; i < maxLen; i++ {
-			data := m.data[i+m.offset]
-			v := data.Name
+			data := m.data[m.view[i+m.offset]]
+			lock := ""
 			if data.Lock {
-				v += " 🔒"
+				lock = " 🔒"
 			}
 			if i == m.idx && m.focus {
-				v = lip.NewStyle().Foreground(design.Special).Bold(true).Render(fmt.Sprintf("▶ %s", v))
+				style := lip.NewStyle().Foreground(design.Special).Bold(true)
+				items[i+1] = style.Render("▶ ") + highlightMatches(data.Name, m.filter.query(), style) + style.Render(lock)
+			} else {
+				items[i+1] = highlightMatches(data.Name, m.filter.query(), lip.NewStyle()) + lock
 			}
-			items[i+1] = v
 		}
+		if len(m.view) == 0 && m.filter.query() != "" {
+			items[1] = lip.NewStyle().Foreground(design.Subtle).Render("no matches")
+		}
+	}
+
+	if m.filter.shown() {
+		items[len(items)-1] = m.filter.input.View()
 	}
 
 	if m.inputMode {
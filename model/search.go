@@ -0,0 +1,298 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	lip "github.com/charmbracelet/lipgloss"
+	"github.com/onfirebyte/chatt/design"
+	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/request"
+	"github.com/onfirebyte/chatt/signal"
+)
+
+// searchPageSize is how many results are asked of the server.
+const searchPageSize = 50
+
+var searchMatchStyle = lip.NewStyle().Foreground(lip.Color("0")).Background(design.Away)
+
+type chatSearch struct {
+	key      string
+	query    string
+	messages []chatMessage
+	err      error
+}
+
+// searchState is the ctrl+f search of a conversation. Hits are the loaded
+// messages that match plus, once the server answers, older matches that
+// aren't loaded.
+type searchState struct {
+	input  textinput.Model
+	typing bool
+
+	query string
+	re    *regexp.Regexp
+	err   error
+
+	// current is the ID of the hit jumped to last.
+	current string
+	// remote holds the server's results that aren't loaded, oldest first.
+	remote  []chatMessage
+	loading bool
+	// offset is where the timeline was scrolled before searching.
+	offset int
+}
+
+func newSearch(offset int) *searchState {
+	ti := textinput.New()
+	ti.Prompt = "search: "
+	ti.Placeholder = "text, or /regex/"
+	ti.CharLimit = 128
+	ti.Focus()
+	return &searchState{
+		input:  ti,
+		typing: true,
+		offset: offset,
+	}
+}
+
+// compileSearch builds the pattern for query. A query between slashes is a
+// regular expression; anything else a case-insensitive substring.
+func compileSearch(query string) (*regexp.Regexp, bool, error) {
+	if query == "" {
+		return nil, false, nil
+	}
+	if len(query) > 2 && strings.HasPrefix(query, "/") && strings.HasSuffix(query, "/") {
+		re, err := regexp.Compile(query[1 : len(query)-1])
+		return re, true, err
+	}
+	return regexp.MustCompile("(?i)" + regexp.QuoteMeta(query)), false, nil
+}
+
+// SearchHistory asks the server for messages of target matching query.
+func SearchHistory(client *request.Client, key string, target signal.Connect, query string) tea.Cmd {
+	return func() tea.Msg {
+		_, regex, _ := compileSearch(query)
+		text := query
+		if regex {
+			text = query[1 : len(query)-1]
+		}
+
+		res, err := client.SearchHistory(target, text, regex, searchPageSize)
+		if err != nil {
+			return chatSearch{key: key, query: query, err: err}
+		}
+
+		messages := make([]chatMessage, len(res))
+		for i, v := range res {
+			messages[i] = chatMessage{Message: v, Kind: dto.TypeMessage}
+		}
+		return chatSearch{key: key, query: query, messages: messages}
+	}
+}
+
+func (s *searchState) matches(v chatMessage) bool {
+	return s.re != nil && v.Kind == dto.TypeMessage && !v.Deleted && s.re.MatchString(v.Data)
+}
+
+// hits returns the IDs of every hit in conv, oldest first.
+func (s *searchState) hits(conv *conversation) []string {
+	res := []string{}
+	for _, v := range s.remote {
+		if s.matches(v) {
+			res = append(res, v.ID)
+		}
+	}
+	for _, v := range conv.data {
+		if v.ID != "" && s.matches(v) {
+			res = append(res, v.ID)
+		}
+	}
+	return res
+}
+
+// showingRemote reports whether the current hit is one of the server's
+// results, which are shown in place of the timeline.
+func (s *searchState) showingRemote() bool {
+	return slices.ContainsFunc(s.remote, func(v chatMessage) bool {
+		return v.ID == s.current
+	})
+}
+
+// openSearch starts typing a search in conv.
+func (m *Chat) openSearch(conv *conversation) tea.Cmd {
+	if conv == nil || conv.editing != "" {
+		return nil
+	}
+	m.closeThread(conv)
+	if conv.search == nil {
+		conv.search = newSearch(conv.offset)
+	}
+	conv.search.typing = true
+	return conv.search.input.Focus()
+}
+
+func (m *Chat) closeSearch(conv *conversation) {
+	if conv == nil || conv.search == nil {
+		return
+	}
+	conv.offset = conv.search.offset
+	conv.search = nil
+}
+
+// jumpSearch moves to the hit dir steps away from the current one, wrapping
+// around at either end, and scrolls it into view.
+func (m *Chat) jumpSearch(conv *conversation, dir int) {
+	s := conv.search
+	hits := s.hits(conv)
+	if len(hits) == 0 {
+		return
+	}
+
+	pos := slices.Index(hits, s.current)
+	if pos < 0 {
+		pos = len(hits)
+		if dir > 0 {
+			pos = -1
+		}
+	}
+	s.current = hits[(pos+dir+len(hits))%len(hits)]
+
+	for i, v := range visible(conv) {
+		if v.ID == s.current {
+			m.scrollTo(conv, i)
+		}
+	}
+}
+
+// updateSearch handles a key while conv has a search open. It reports false
+// for keys that should reach the composer.
+func (m *Chat) updateSearch(conv *conversation, msg tea.KeyMsg) (bool, tea.Cmd) {
+	s := conv.search
+	if s == nil {
+		return false, nil
+	}
+
+	if !s.typing {
+		switch msg.String() {
+		case "esc":
+			m.closeSearch(conv)
+			return true, nil
+		case "n", "N":
+			// Only while the composer is empty, so we can still type them.
+			if m.composer.Value() != "" {
+				return false, nil
+			}
+			dir := -1
+			if msg.String() == "N" {
+				dir = 1
+			}
+			m.jumpSearch(conv, dir)
+			return true, nil
+		}
+		return false, nil
+	}
+
+	switch msg.String() {
+	case "esc":
+		m.closeSearch(conv)
+		return true, nil
+	case "enter":
+		if s.re == nil {
+			return true, nil
+		}
+		s.typing = false
+		s.input.Blur()
+		s.current = ""
+		m.jumpSearch(conv, -1)
+
+		s.remote = nil
+		if conv.connection == nil || conv.connection.Protocol == 0 {
+			return true, nil
+		}
+		s.loading = true
+		return true, SearchHistory(m.client, conv.key, conv.target, s.query)
+	}
+
+	var cmd tea.Cmd
+	s.input, cmd = s.input.Update(msg)
+	if s.input.Value() != s.query {
+		s.query = s.input.Value()
+		s.re, _, s.err = compileSearch(s.query)
+		s.current = ""
+		s.remote = nil
+	}
+	return true, cmd
+}
+
+// receiveSearch keeps the server's results that aren't loaded already.
+func (m *Chat) receiveSearch(conv *conversation, msg chatSearch) {
+	s := conv.search
+	if s == nil || s.query != msg.query {
+		return
+	}
+	s.loading = false
+	if msg.err != nil {
+		s.err = msg.err
+		return
+	}
+
+	loaded := map[string]bool{}
+	for _, v := range conv.data {
+		loaded[v.ID] = true
+	}
+	s.remote = nil
+	for _, v := range msg.messages {
+		if !loaded[v.ID] {
+			conv.unseal(&v)
+			s.remote = append(s.remote, v)
+		}
+	}
+
+	if s.current == "" {
+		m.jumpSearch(conv, -1)
+	}
+}
+
+// searchStatus is the header counter for the search of conv.
+func searchStatus(conv *conversation) string {
+	s := conv.search
+	if s == nil || s.re == nil {
+		return ""
+	}
+
+	hits := s.hits(conv)
+	status := fmt.Sprintf("[%d/%d]", slices.Index(hits, s.current)+1, len(hits))
+	if len(hits) == 0 {
+		status = "[no matches]"
+	}
+	if s.loading {
+		status += " searching server…"
+	}
+	return status
+}
+
+// searchHelp is the status line while conv has a search open.
+func searchHelp(conv *conversation, width int) string {
+	s := conv.search
+	if s.typing {
+		line := s.input.View()
+		if s.err != nil {
+			line += " " + design.ErrorText.Render(s.err.Error())
+		}
+		return lip.NewStyle().MaxWidth(width).Render(line)
+	}
+
+	help := "n older · N newer · ctrl+f edit · esc close"
+	if s.showingRemote() {
+		help = "older result from the server · " + help
+	}
+	if s.err != nil {
+		help = design.ErrorText.Render(s.err.Error()) + " · " + help
+	}
+	return eventStyle.MaxWidth(width).Render(help)
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/onfirebyte/chatt/dto"
+)
+
+func selectable(v chatMessage) bool {
+	return v.Kind == dto.TypeMessage && v.ID != "" && !v.Deleted
+}
+
+func (m *Chat) selectedMessage(conv *conversation) (chatMessage, int) {
+	if conv == nil || conv.selected == "" {
+		return chatMessage{}, -1
+	}
+	for i, v := range visible(conv) {
+		if v.ID == conv.selected {
+			return v, i
+		}
+	}
+	return chatMessage{}, -1
+}
+
+// moveSelection steps the selection dir messages through conv, starting from
+// the newest message, and scrolls it into view.
+func (m *Chat) moveSelection(conv *conversation, dir int) {
+	if conv == nil || conv.editing != "" {
+		return
+	}
+
+	data := visible(conv)
+	_, idx := m.selectedMessage(conv)
+	if idx < 0 {
+		if dir > 0 {
+			return
+		}
+		idx = len(data)
+	}
+
+	for i := idx + dir; i >= 0 && i < len(data); i += dir {
+		if selectable(data[i]) {
+			conv.selected = data[i].ID
+			m.scrollTo(conv, i)
+			return
+		}
+	}
+
+	// Stepping past the newest message leaves selection mode.
+	if dir > 0 {
+		conv.selected = ""
+	}
+}
+
+// scrollTo adjusts the offset of conv so the message at idx is visible.
+func (m *Chat) scrollTo(conv *conversation, idx int) {
+	lines, starts := m.renderLines(conv)
+	start := starts[idx]
+	end := len(lines)
+	if idx+1 < len(starts) {
+		end = starts[idx+1]
+	}
+
+	height := m.contentHeight()
+	top := len(lines) - height - conv.offset
+	if start < top {
+		conv.offset = len(lines) - height - start
+	}
+	if end > len(lines)-conv.offset {
+		conv.offset = len(lines) - end
+	}
+	conv.offset = max(conv.offset, 0)
+}
+
+func (m *Chat) cancelSelection(conv *conversation) {
+	if conv == nil {
+		return
+	}
+	if conv.editing != "" {
+		conv.editing = ""
+		m.restoreDraft(conv)
+		return
+	}
+	if conv.selected == "" {
+		m.closeThread(conv)
+		return
+	}
+	conv.selected = ""
+}
+
+func (m *Chat) ownSelected(conv *conversation) (chatMessage, bool) {
+	v, idx := m.selectedMessage(conv)
+	return v, idx >= 0 && conv.connection != nil && v.User == m.client.UserName()
+}
+
+// startEdit loads the selected message into the composer. It reports false
+// if there is nothing of ours to edit.
+func (m *Chat) startEdit(conv *conversation) bool {
+	v, ok := m.ownSelected(conv)
+	if !ok || conv.editing != "" {
+		return false
+	}
+
+	m.stashDraft(conv)
+	conv.editing = v.ID
+	m.composer.SetValue(v.Data)
+	m.resizeComposer()
+	return true
+}
+
+// deleteSelected deletes the selected message. It reports false if there is
+// nothing of ours to delete.
+func (m *Chat) deleteSelected(conv *conversation) (bool, tea.Cmd) {
+	v, ok := m.ownSelected(conv)
+	if !ok || conv.editing != "" {
+		return false, nil
+	}
+
+	conv.selected = ""
+	if err := conv.connection.Send(dto.TypeDelete, dto.Message{ID: v.ID}); err != nil {
+		key := conv.key
+		return true, func() tea.Msg {
+			return chatError{key: key, err: err}
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"log"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/store"
+)
+
+type chatStored struct {
+	key      string
+	messages []chatMessage
+}
+
+// LoadStored reads what the local store has of a conversation, trimming
+// the file to the limit first.
+func LoadStored(s *store.Store, key string) tea.Cmd {
+	return func() tea.Msg {
+		if err := s.Compact(key); err != nil {
+			log.Println("compact stored messages:", err)
+		}
+		res, err := s.Load(key)
+		if err != nil {
+			log.Println("load stored messages:", err)
+		}
+
+		messages := make([]chatMessage, len(res))
+		for i, v := range res {
+			messages[i] = chatMessage{Message: v, Kind: dto.TypeMessage}
+		}
+		return chatStored{key: key, messages: messages}
+	}
+}
+
+// openStore opens the local store once we know who we are.
+func (m *Chat) openStore(user string) {
+	if m.options.StoreLimit <= 0 || m.store != nil {
+		return
+	}
+
+	s, err := store.Open(m.client.URL(), user, m.options.StoreLimit)
+	if err != nil {
+		log.Println("open store:", err)
+		return
+	}
+	m.store = s
+}
+
+// persist appends user messages to the local store of conv. Messages of
+// an encrypted conversation are only kept when asked to, since the store
+// holds them decrypted.
+func (m *Chat) persist(conv *conversation, msgs ...chatMessage) {
+	if m.store == nil || (conv.shared != nil && !m.options.StoreEncrypted) {
+		return
+	}
+
+	res := make([]dto.Message, 0, len(msgs))
+	for _, v := range msgs {
+		// Messages we couldn't decrypt wait for a readable copy, and
+		// unverified ones are never kept.
+		if v.Kind == dto.TypeMessage && !v.Sealed && !v.Unverified {
+			res = append(res, v.Message)
+		}
+	}
+	if err := m.store.Append(conv.key, res...); err != nil {
+		log.Println("store messages:", err)
+	}
+}
+
+// persistChange stores the message with id as conv has it now.
+func (m *Chat) persistChange(conv *conversation, id string) {
+	for _, v := range conv.data {
+		if v.ID == id {
+			m.persist(conv, v)
+		}
+	}
+}
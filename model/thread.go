@@ -0,0 +1,142 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	lip "github.com/charmbracelet/lipgloss"
+	"github.com/onfirebyte/chatt/design"
+	"github.com/onfirebyte/chatt/dto"
+)
+
+var (
+	quoteLineStyle  = lip.NewStyle().Foreground(lip.Color("246"))
+	replyBadgeStyle = lip.NewStyle().Foreground(design.Special)
+)
+
+// visible returns the messages shown for conv: the whole timeline, the
+// parent and replies of the open thread, or the server's search results.
+func visible(conv *conversation) []chatMessage {
+	if conv.search != nil && conv.search.showingRemote() {
+		return conv.search.remote
+	}
+	if conv.thread == "" {
+		return conv.data
+	}
+
+	res := []chatMessage{}
+	for _, v := range conv.data {
+		if v.ID == conv.thread || (v.Kind == dto.TypeMessage && v.Parent == conv.thread) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// replyCounts counts the replies to each message of conv.
+func replyCounts(conv *conversation) map[string]int {
+	res := map[string]int{}
+	for _, v := range conv.data {
+		if v.Parent != "" && !v.Deleted {
+			res[v.Parent]++
+		}
+	}
+	return res
+}
+
+// quoteLine summarises the parent of a reply on one line.
+func quoteLine(conv *conversation, parent string, width int) string {
+	text := "↪ reply to an earlier message"
+	for _, v := range conv.data {
+		if v.ID != parent {
+			continue
+		}
+		body := strings.Join(strings.Fields(v.Data), " ")
+		if v.Deleted {
+			body = "message deleted"
+		}
+		text = fmt.Sprintf("↪ %s: %s", v.User, body)
+	}
+	return quoteLineStyle.MaxWidth(width).Render(truncate(text, width))
+}
+
+func replyBadge(n int) string {
+	if n == 1 {
+		return replyBadgeStyle.Render(" 1 reply")
+	}
+	return replyBadgeStyle.Render(fmt.Sprintf(" %d replies", n))
+}
+
+// truncate shortens s to width cells, ending it with an ellipsis.
+func truncate(s string, width int) string {
+	if lip.Width(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && lip.Width(string(runes))+1 > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// stashDraft keeps the composer contents in the draft of whichever view of
+// conv is showing.
+func (m *Chat) stashDraft(conv *conversation) {
+	if conv.thread != "" {
+		conv.threadDraft = m.composer.Value()
+	} else {
+		conv.draft = m.composer.Value()
+	}
+}
+
+// restoreDraft puts the draft of the view of conv that is showing back in
+// the composer.
+func (m *Chat) restoreDraft(conv *conversation) {
+	m.composer.Placeholder = "Type a message..."
+	if conv.thread != "" {
+		m.composer.Placeholder = "Reply in thread..."
+		m.composer.SetValue(conv.threadDraft)
+	} else {
+		m.composer.SetValue(conv.draft)
+	}
+	m.resizeComposer()
+}
+
+// openThread shows the thread of the selected message of conv. It reports
+// false if nothing is selected.
+func (m *Chat) openThread(conv *conversation) bool {
+	v, idx := m.selectedMessage(conv)
+	if idx < 0 || conv.editing != "" {
+		return false
+	}
+
+	root := v.ID
+	if v.Parent != "" {
+		root = v.Parent
+	}
+	if conv.thread == root {
+		return true
+	}
+
+	m.closeThread(conv)
+	m.stashDraft(conv)
+	conv.thread = root
+	conv.threadDraft = ""
+	conv.mainOffset = conv.offset
+	conv.offset = 0
+	conv.selected = ""
+	m.restoreDraft(conv)
+	return true
+}
+
+// closeThread goes back to the main timeline of conv.
+func (m *Chat) closeThread(conv *conversation) {
+	if conv == nil || conv.thread == "" {
+		return
+	}
+	conv.thread = ""
+	conv.threadDraft = ""
+	conv.offset = conv.mainOffset
+	conv.selected = ""
+	m.restoreDraft(conv)
+}
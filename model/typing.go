@@ -0,0 +1,139 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/request"
+)
+
+const (
+	// typingThrottle is how often a typing-start is repeated while the user
+	// keeps typing, so the other side doesn't expire it.
+	typingThrottle = 3 * time.Second
+	// typingIdle is how long after the last keystroke we send typing-stop.
+	typingIdle = 5 * time.Second
+	// typingExpiry drops someone else's typing state if no stop arrives.
+	typingExpiry = 6 * time.Second
+	// typingCrowd is how many names are listed before collapsing them.
+	typingCrowd = 3
+)
+
+type (
+	typingReceived struct {
+		key        string
+		connection *request.Conn
+		typing     dto.Typing
+	}
+	typingIdleCheck struct {
+		key string
+	}
+	typingExpired struct {
+		key string
+	}
+)
+
+// typingState is our own typing state in a conversation, plus who else is
+// typing there and until when.
+type typingState struct {
+	active  bool
+	sentAt  time.Time
+	lastKey time.Time
+	others  map[string]time.Time
+}
+
+// keystroke records that the user edited the draft of conv and sends a
+// throttled typing-start.
+func (t *typingState) keystroke(conv *conversation) tea.Cmd {
+	now := time.Now()
+	t.lastKey = now
+	if t.active && now.Sub(t.sentAt) < typingThrottle {
+		return nil
+	}
+
+	t.active = true
+	t.sentAt = now
+
+	key := conv.key
+	return tea.Batch(
+		conv.send(dto.TypeTyping, dto.Typing{Typing: true}),
+		tea.Tick(typingIdle, func(time.Time) tea.Msg {
+			return typingIdleCheck{key: key}
+		}))
+}
+
+// stop sends typing-stop if we told the conversation we were typing.
+func (t *typingState) stop(conv *conversation) tea.Cmd {
+	if !t.active {
+		return nil
+	}
+	t.active = false
+	return conv.send(dto.TypeTyping, dto.Typing{Typing: false})
+}
+
+// idle is called when a typingIdleCheck fires, and stops typing if no key
+// was pressed for typingIdle. Otherwise it checks again later.
+func (t *typingState) idle(conv *conversation) tea.Cmd {
+	if !t.active {
+		return nil
+	}
+	wait := typingIdle - time.Since(t.lastKey)
+	if wait <= 0 {
+		return t.stop(conv)
+	}
+
+	key := conv.key
+	return tea.Tick(wait, func(time.Time) tea.Msg {
+		return typingIdleCheck{key: key}
+	})
+}
+
+// received updates who else is typing from an incoming event.
+func (t *typingState) received(key string, typing dto.Typing) tea.Cmd {
+	if !typing.Typing {
+		delete(t.others, typing.User)
+		return nil
+	}
+
+	if t.others == nil {
+		t.others = map[string]time.Time{}
+	}
+	t.others[typing.User] = time.Now().Add(typingExpiry)
+	return tea.Tick(typingExpiry, func(time.Time) tea.Msg {
+		return typingExpired{key: key}
+	})
+}
+
+// prune forgets users whose typing state has expired.
+func (t *typingState) prune() {
+	now := time.Now()
+	for user, until := range t.others {
+		if !now.Before(until) {
+			delete(t.others, user)
+		}
+	}
+}
+
+// String describes who is typing, such as "alice and bob are typing…".
+func (t *typingState) String() string {
+	names := make([]string, 0, len(t.others))
+	for user := range t.others {
+		names = append(names, user)
+	}
+	sort.Strings(names)
+
+	switch {
+	case len(names) == 0:
+		return ""
+	case len(names) == 1:
+		return fmt.Sprintf("%s is typing…", names[0])
+	case len(names) > typingCrowd:
+		return "several people are typing…"
+	default:
+		return fmt.Sprintf("%s and %s are typing…", strings.Join(names[:len(names)-1], ", "), names[len(names)-1])
+	}
+}
@@ -2,11 +2,16 @@ package model
 
 import (
 	"fmt"
+	"slices"
+	"sort"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	lip "github.com/charmbracelet/lipgloss"
 	"github.com/onfirebyte/chatt/common"
 	"github.com/onfirebyte/chatt/design"
+	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/request"
 	"github.com/onfirebyte/chatt/signal"
 )
 
@@ -22,70 +27,150 @@ type UserListTab struct {
 
 	offset int
 
-	data      []string
-	error     error
-	fetchFunc func() ([]string, error)
+	data   []string
+	error  error
+	client *request.Client
+
+	// view holds the indexes of data that pass the filter; idx and offset
+	// point into it.
+	filter listFilter
+	view   []int
+
+	presence      map[string]dto.PresenceStatus
+	events        *request.Conn
+	eventsAttempt int
+	lastActivity  time.Time
+	away          bool
 }
 
-func NewUserListTabModel(name string, fetchFunc func() ([]string, error)) UserListTab {
+func NewUserListTabModel(name string, client *request.Client) UserListTab {
 	return UserListTab{
-		title:     name,
-		fetchFunc: fetchFunc,
+		title:        name,
+		client:       client,
+		presence:     map[string]dto.PresenceStatus{},
+		lastActivity: time.Now(),
+		filter:       newListFilter(),
 	}
 }
 
 func (m UserListTab) Init() tea.Cmd {
-	return func() tea.Msg {
-		if m.fetchFunc == nil {
-			return UserListResult{
-				Value: []string{},
-				Err:   nil,
-			}
-		}
+	return m.fetch
+}
 
-		res, err := m.fetchFunc()
+func (m UserListTab) fetch() tea.Msg {
+	if m.client == nil {
 		return UserListResult{
-			Value: res,
-			Err:   err,
+			Value: []string{},
+			Err:   nil,
 		}
 	}
+
+	res, err := m.client.GetAllUsers()
+	return UserListResult{
+		Value: res,
+		Err:   err,
+	}
+}
+
+// rows is how many users fit in the tab.
+func (m *UserListTab) rows() int {
+	rows := m.height - 4
+	if m.filter.shown() {
+		rows--
+	}
+	return max(rows, 1)
+}
+
+// selected returns the user under the cursor.
+func (m *UserListTab) selected() string {
+	if i := m.idx + m.offset; i >= 0 && i < len(m.view) {
+		return m.data[m.view[i]]
+	}
+	return ""
+}
+
+// refresh rebuilds the filtered view after the data or the filter changed,
+// keeping the cursor on the same user.
+func (m *UserListTab) refresh(selected string) {
+	m.view = filterIndexes(m.data, m.filter.query())
+	pos := slices.IndexFunc(m.view, func(i int) bool {
+		return m.data[i] == selected
+	})
+	m.idx, m.offset = keepSelection(pos, m.offset, m.rows(), len(m.view))
+}
+
+// sortData puts online users first and keeps the cursor on the same user.
+func (m *UserListTab) sortData() {
+	selected := m.selected()
+
+	sort.SliceStable(m.data, func(i, j int) bool {
+		ri, rj := presenceRank(m.presence[m.data[i]]), presenceRank(m.presence[m.data[j]])
+		if ri != rj {
+			return ri < rj
+		}
+		return m.data[i] < m.data[j]
+	})
+	m.refresh(selected)
+}
+
+// setAway reports our own presence over the control connection.
+func (m *UserListTab) setAway(away bool) {
+	m.away = away
+	if m.events == nil {
+		return
+	}
+	status := dto.Online
+	if away {
+		status = dto.Away
+	}
+	m.events.Send(dto.TypePresence, dto.Presence{Status: status})
 }
 
 func (m UserListTab) Update(msg tea.Msg) (UserListTab, tea.Cmd) {
+	if _, ok := msg.(tea.KeyMsg); ok {
+		m.lastActivity = time.Now()
+		if m.away {
+			m.setAway(false)
+		}
+	}
+
 	switch msg := msg.(type) {
 	case signal.Size:
 		m.width = msg.Width
 		m.height = msg.Height
 	case signal.HomeTabSelected:
 		m.focus = bool(msg)
+		if !m.focus {
+			m.filter.stop()
+		}
 	case tea.KeyMsg:
-		if msg.String() == "r" && m.focus && m.fetchFunc != nil {
-			m.loading = true
-			return m, func() tea.Msg {
-				res, err := m.fetchFunc()
-				return UserListResult{
-					Value: res,
-					Err:   err,
-				}
-			}
+		if !m.focus {
+			break
+		}
+		selected := m.selected()
+		if ok, cmd := m.filter.update(msg); ok {
+			m.refresh(selected)
+			return m, cmd
 		}
 
 		switch msg.String() {
+		case "/":
+			cmd := m.filter.start()
+			m.refresh(selected)
+			return m, cmd
+		case "esc":
+			m.filter.clear()
+			m.refresh(selected)
 		case "r":
-			if m.focus && m.fetchFunc != nil {
-				return m, func() tea.Msg {
-					res, err := m.fetchFunc()
-					return UserListResult{
-						Value: res,
-						Err:   err,
-					}
-				}
+			if m.client != nil {
+				m.loading = true
+				return m, m.fetch
 			}
 		case "down":
 			if m.focus {
-				if m.idx < min(len(m.data)-1, m.height-5) {
+				if m.idx < min(len(m.view)-1, m.rows()-1) {
 					m.idx++
-				} else if m.idx+m.offset < len(m.data)-1 {
+				} else if m.idx+m.offset < len(m.view)-1 {
 					m.offset++
 				}
 			}
@@ -98,11 +183,11 @@ func (m UserListTab) Update(msg tea.Msg) (UserListTab, tea.Cmd) {
 				}
 			}
 		case "enter":
-			if m.focus && m.idx >= 0 && m.idx < len(m.data) {
+			if user := m.selected(); user != "" {
 				return m, func() tea.Msg {
 					return signal.Connect{
 						IsRoom: false,
-						Value:  m.data[m.idx+m.offset],
+						Value:  user,
 					}
 				}
 			}
@@ -113,17 +198,70 @@ func (m UserListTab) Update(msg tea.Msg) (UserListTab, tea.Cmd) {
 		m.loading = false
 		m.data = msg.Value
 		m.error = msg.Err
+		m.sortData()
+
+	case signal.UserInfo:
+		if m.client != nil && m.events == nil {
+			return m, tea.Batch(connectEvents(m.client), checkIdle())
+		}
+
+	case presenceConn:
+		m.events = msg.connection
+		m.eventsAttempt = 0
+		if m.away {
+			m.setAway(true)
+		}
+		return m, readPresence(m.events)
+
+	case presenceReceived:
+		if msg.connection != m.events {
+			break
+		}
+		if _, ok := m.presence[msg.presence.User]; !ok && !slices.Contains(m.data, msg.presence.User) {
+			m.data = append(m.data, msg.presence.User)
+		}
+		m.presence[msg.presence.User] = msg.presence.Status
+		m.sortData()
+		return m, readPresence(m.events)
+
+	case presenceError:
+		if msg.connection != m.events {
+			break
+		}
+		m.events = nil
+		for user := range m.presence {
+			m.presence[user] = dto.Offline
+		}
+		m.sortData()
+		// An expired token is not permanent: the dial waits for a new one.
+		if request.IsPermanent(msg.err) && !request.IsUnauthorized(msg.err) {
+			break
+		}
+		m.eventsAttempt++
+		return m, tea.Tick(reconnectDelay(m.eventsAttempt), func(time.Time) tea.Msg {
+			return presenceReconnect{}
+		})
+
+	case presenceReconnect:
+		if m.events == nil && m.client != nil {
+			return m, connectEvents(m.client)
+		}
+
+	case presenceIdle:
+		if !m.away && time.Since(m.lastActivity) >= awayAfter {
+			m.setAway(true)
+		}
+		return m, checkIdle()
+
+	case tea.QuitMsg:
+		if m.events != nil {
+			m.events.Close()
+		}
 
 	case signal.Refetch:
-		if msg == "all" && m.fetchFunc != nil {
+		if msg == "all" && m.client != nil {
 			m.loading = true
-			return m, func() tea.Msg {
-				res, err := m.fetchFunc()
-				return UserListResult{
-					Value: res,
-					Err:   err,
-				}
-			}
+			return m, m.fetch
 		}
 	}
 
@@ -139,7 +277,10 @@ func (m UserListTab) View() string {
 		tabStyle = design.Tab
 	}
 
-	items := make([]string, max(min(len(m.data)+1, m.height-3), 2))
+	items := make([]string, max(min(len(m.view)+1, m.height-3), 2))
+	if m.filter.shown() {
+		items = make([]string, max(m.height-3, 2))
+	}
 
 	title := m.title
 	if m.loading {
@@ -147,17 +288,27 @@ func (m UserListTab) View() string {
 	}
 
 	items[0] = design.ListHeader.Width(m.width - 4).Render(title)
-	maxLen := min(len(m.data), m.height-4)
+	maxLen := min(len(m.view)-m.offset, m.rows())
 	if m.error != nil {
 		items[1] = design.ErrorText.Render(m.error.Error())
 	} else {
 		for i := 0; i < maxLen; i++ {
-			v := m.data[i+m.offset]
+			name := m.data[m.view[i+m.offset]]
+			dot := presenceDot(m.presence[name]) + " "
 			if i == m.idx && m.focus {
-				v = lip.NewStyle().Foreground(design.Special).Bold(true).Render(fmt.Sprintf("▶ %s", v))
+				style := lip.NewStyle().Foreground(design.Special).Bold(true)
+				items[i+1] = style.Render("▶ ") + dot + highlightMatches(name, m.filter.query(), style)
+			} else {
+				items[i+1] = dot + highlightMatches(name, m.filter.query(), lip.NewStyle())
 			}
-			items[i+1] = v
 		}
+		if len(m.view) == 0 && m.filter.query() != "" {
+			items[1] = lip.NewStyle().Foreground(design.Subtle).Render("no matches")
+		}
+	}
+
+	if m.filter.shown() {
+		items[len(items)-1] = m.filter.input.View()
 	}
 
 	return tabStyle.
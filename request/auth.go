@@ -0,0 +1,129 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/onfirebyte/chatt/dto"
+)
+
+// Expired delivers a value whenever the server rejected the token and it
+// could not be refreshed. Requests made meanwhile wait until SetSession
+// brings new credentials, then retry.
+func (c *Client) Expired() <-chan struct{} {
+	return c.expired
+}
+
+// withAuth runs try with the current token. If the server rejects it, the
+// token is refreshed, or else, when wait is set, the user is asked to log
+// in again; try then runs once more with the new token.
+func (c *Client) withAuth(wait bool, try func(token string) error) error {
+	token := c.Token()
+	err := try(token)
+	if !IsUnauthorized(err) {
+		return err
+	}
+
+	if c.refresh(token) {
+		token = c.Token()
+		if err = try(token); !IsUnauthorized(err) {
+			return err
+		}
+	}
+	if wait && c.waitLogin(token) {
+		return try(c.Token())
+	}
+	return err
+}
+
+// refresh trades the refresh token for a new access token, reporting whether
+// there is one now. Callers that saw the same rejected token share a single
+// refresh.
+func (c *Client) refresh(rejected string) bool {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	c.mu.RLock()
+	token, refreshToken := c.token, c.refreshToken
+	c.mu.RUnlock()
+	if token != rejected {
+		return token != ""
+	}
+	if refreshToken == "" {
+		return false
+	}
+
+	body, err := json.Marshal(dto.Refresh{RefreshToken: refreshToken})
+	if err != nil {
+		return false
+	}
+	resp, err := c.http.Post(c.baseURL+"/refresh", "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Println("refresh:", err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	var session dto.Session
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&session) != nil || session.Token == "" {
+		return false
+	}
+	if session.RefreshToken == "" {
+		session.RefreshToken = refreshToken
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// A login that finished meanwhile wins.
+	if c.token == rejected {
+		c.token = session.Token
+		c.refreshToken = session.RefreshToken
+	}
+	return true
+}
+
+// waitLogin blocks until credentials replace the rejected token, reporting
+// whether there are any; logging out leaves none.
+func (c *Client) waitLogin(rejected string) bool {
+	c.mu.Lock()
+	if c.token != rejected {
+		ok := c.token != ""
+		c.mu.Unlock()
+		return ok
+	}
+	if c.renewed == nil {
+		c.renewed = make(chan struct{})
+		select {
+		case c.expired <- struct{}{}:
+		default:
+		}
+	}
+	renewed := c.renewed
+	c.mu.Unlock()
+
+	<-renewed
+	return c.Token() != ""
+}
+
+// send does req with the current token, refreshing it when the server
+// rejects it, and with wait also waiting for a new login. req must have no
+// body, since it may be sent twice.
+func (c *Client) send(req *http.Request, wait bool) (*http.Response, error) {
+	var resp *http.Response
+	err := c.withAuth(wait, func(token string) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		var err error
+		resp, err = c.http.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusUnauthorized {
+			resp.Body.Close()
+			return &StatusError{StatusCode: resp.StatusCode}
+		}
+		return nil
+	})
+	return resp, err
+}
@@ -0,0 +1,78 @@
+package request
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/onfirebyte/chatt/dto"
+)
+
+// Client talks to a single chatt server. It is safe for concurrent use, so
+// the same Client can be shared between the models of one session.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	dialer  *websocket.Dialer
+
+	mu           sync.RWMutex
+	userName     string
+	token        string
+	refreshToken string
+
+	// renewed is closed when new credentials arrive for the requests
+	// waiting on a rejected token; expired tells the UI to ask for them.
+	renewed   chan struct{}
+	expired   chan struct{}
+	refreshMu sync.Mutex
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: baseURL,
+		http:    &http.Client{},
+		dialer:  websocket.DefaultDialer,
+		expired: make(chan struct{}, 1),
+	}
+}
+
+func (c *Client) URL() string {
+	return c.baseURL
+}
+
+func (c *Client) SetCredentials(userName string, token string) {
+	c.SetSession(userName, dto.Session{Token: token})
+}
+
+// SetSession switches to the session of userName, waking the requests that
+// were waiting for it.
+func (c *Client) SetSession(userName string, session dto.Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.userName = userName
+	c.token = session.Token
+	c.refreshToken = session.RefreshToken
+	if c.renewed != nil {
+		close(c.renewed)
+		c.renewed = nil
+	}
+}
+
+// Session returns the current tokens.
+func (c *Client) Session() dto.Session {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return dto.Session{Token: c.token, RefreshToken: c.refreshToken}
+}
+
+func (c *Client) UserName() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.userName
+}
+
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
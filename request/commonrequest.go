@@ -1,66 +1,109 @@
 package request
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"net/url"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/onfirebyte/chatt/common"
 	"github.com/onfirebyte/chatt/dto"
 )
 
-func CreateUser(name string, password string) (string, error) {
-	reqUrl, err := url.Parse(common.URL)
-	if err != nil {
-		log.Println(err)
-	}
+// Login returns a fresh session for an existing account.
+func (c *Client) Login(name string, password string) (dto.Session, error) {
+	return c.authenticate("login", dto.Credentials{Name: name, Password: password})
+}
 
-	reqUrl.Path = "/users"
+// Register creates an account and returns its first session.
+func (c *Client) Register(name string, password string) (dto.Session, error) {
+	return c.authenticate("register", dto.Credentials{Name: name, Password: password})
+}
 
-	q := reqUrl.Query()
-	q.Set("user", name)
-	q.Set("password", password)
-	reqUrl.RawQuery = q.Encode()
+// authenticate posts creds to endpoint. Refusals come back as the Err
+// values of this package.
+func (c *Client) authenticate(endpoint string, creds dto.Credentials) (dto.Session, error) {
+	var session dto.Session
+	body, err := json.Marshal(creds)
+	if err != nil {
+		return session, err
+	}
 
-	resp, err := http.Post(reqUrl.String(), "application/json", nil)
+	resp, err := c.http.Post(c.baseURL+"/"+endpoint, "application/json", bytes.NewReader(body))
 	if err != nil {
-		log.Println(err)
-		return "", err
+		return session, err
 	}
 
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
 	if err != nil {
-		log.Println(err)
-		return "", err
+		return session, err
 	}
-	log.Println("BODY", string(body))
 
-	// check if status code is 2xx
-	if resp.StatusCode/100 != 2 {
-		if resp.StatusCode == http.StatusUnauthorized {
-			return "", fmt.Errorf("Please provide a valid password")
-		}
-		return "", fmt.Errorf("Error: %s", string(body))
+	if resp.StatusCode/100 == 2 {
+		err := json.Unmarshal(data, &session)
+		return session, err
+	}
+
+	var authErr dto.AuthError
+	if json.Unmarshal(data, &authErr) == nil && authErr.Code != "" {
+		return session, refusal(authErr, resp.Header.Get("Retry-After"))
 	}
 
-	log.Println("Token acquired", string(body))
-	return string(body), nil
+	switch resp.StatusCode {
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		// Servers predating /login and /register only take the password
+		// in the query string, which we don't send.
+		return session, ErrOldServer
+	case http.StatusProxyAuthRequired:
+		return session, ErrProxyAuth
+	}
+	return session, &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
 }
 
-func GetAllUsers() ([]string, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/users", common.URL))
+// refusal maps a dto.AuthError to the error the UI shows.
+func refusal(e dto.AuthError, retryAfter string) error {
+	switch e.Code {
+	case dto.AuthInvalidName:
+		return ErrInvalidName
+	case dto.AuthNameTaken:
+		return ErrNameTaken
+	case dto.AuthUnknownUser:
+		return ErrUnknownUser
+	case dto.AuthWrongPassword:
+		return ErrWrongPassword
+	case dto.AuthRateLimited:
+		if secs, err := strconv.Atoi(retryAfter); err == nil && secs > 0 {
+			return fmt.Errorf("%w, try again in %s", ErrRateLimited, time.Duration(secs)*time.Second)
+		}
+		return ErrRateLimited
+	}
+	return errors.New(e.Message)
+}
+
+func (c *Client) GetAllUsers() ([]string, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/users", c.baseURL), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.send(req, true)
 	if err != nil {
 		return nil, err
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode/100 != 2 {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
 	var res []string
 	err = json.NewDecoder(resp.Body).Decode(&res)
 
@@ -69,14 +112,23 @@ func GetAllUsers() ([]string, error) {
 	return res, err
 }
 
-func GetAllRooms() ([]dto.Room, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/rooms", common.URL))
+func (c *Client) GetAllRooms() ([]dto.Room, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/rooms", c.baseURL), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.send(req, true)
 	if err != nil {
 		return nil, err
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode/100 != 2 {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
 	var res []dto.Room
 	err = json.NewDecoder(resp.Body).Decode(&res)
 
@@ -86,3 +138,47 @@ func GetAllRooms() ([]dto.Room, error) {
 
 	return res, err
 }
+
+// Me returns the user the current token belongs to, refreshing the token if
+// it expired. A rejected token is a *StatusError that IsAuthError
+// recognizes.
+func (c *Client) Me() (string, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/me", nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.send(req, false)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return "", &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	var res dto.User
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	return res.Name, err
+}
+
+// Logout revokes the current session on the server.
+func (c *Client) Logout() error {
+	req, err := http.NewRequest(http.MethodDelete, c.baseURL+"/me", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.send(req, false)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
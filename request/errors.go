@@ -0,0 +1,67 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// Reasons a login or registration was refused.
+var (
+	ErrInvalidName   = errors.New("names can't be empty or contain : or /")
+	ErrNameTaken     = errors.New("that name is already taken")
+	ErrUnknownUser   = errors.New("no account has that name, register instead")
+	ErrWrongPassword = errors.New("wrong password")
+	ErrRateLimited   = errors.New("too many failed attempts")
+	ErrProxyAuth     = errors.New("the proxy needs credentials, put them in the proxy URL")
+	ErrOldServer     = errors.New("the server is too old to log in to safely, it has no /login or /register")
+)
+
+// ErrNoKey means a user has not published a key for encrypted messages.
+var ErrNoKey = errors.New("no encryption key published")
+
+// StatusError is returned when the server answers with a non-2xx status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("%s: %s", http.StatusText(e.StatusCode), e.Body)
+	}
+	return http.StatusText(e.StatusCode)
+}
+
+// IsAuthError reports whether err means the server rejected our credentials,
+// either on a request or by closing the websocket with a policy violation.
+func IsAuthError(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden
+	}
+	return websocket.IsCloseError(err, websocket.ClosePolicyViolation)
+}
+
+// IsUnauthorized reports whether err means our token is no longer valid: a
+// 401, or a websocket closed with a policy violation. Unlike IsAuthError it
+// leaves out 403, which is about a room password rather than the token.
+func IsUnauthorized(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusUnauthorized
+	}
+	return websocket.IsCloseError(err, websocket.ClosePolicyViolation)
+}
+
+// IsPermanent reports whether retrying the same request can't succeed, such
+// as a 4xx status or a normal websocket closure.
+func IsPermanent(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode/100 == 4
+	}
+	return IsAuthError(err) || websocket.IsCloseError(err, websocket.CloseNormalClosure)
+}
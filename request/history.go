@@ -0,0 +1,85 @@
+package request
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/signal"
+)
+
+// GetHistory returns up to limit messages of the conversation sent before
+// the given message, oldest first. Servers that know its ID page from it,
+// others by its timestamp. A zero before asks for the latest messages.
+func (c *Client) GetHistory(target signal.Connect, before dto.Message, limit int) ([]dto.Message, error) {
+	q := url.Values{}
+	if !before.Timestamp.IsZero() {
+		q.Set("before", before.Timestamp.UTC().Format(time.RFC3339Nano))
+	}
+	if before.ID != "" {
+		q.Set("before_id", before.ID)
+	}
+	q.Set("limit", strconv.Itoa(limit))
+
+	return c.getMessages(target, "messages", q)
+}
+
+// SearchHistory returns up to limit of the newest messages of the
+// conversation matching query, oldest first. query is a case-insensitive
+// substring, or a regular expression when regex is set.
+func (c *Client) SearchHistory(target signal.Connect, query string, regex bool, limit int) ([]dto.Message, error) {
+	q := url.Values{}
+	q.Set("q", query)
+	if regex {
+		q.Set("regex", "1")
+	}
+	q.Set("limit", strconv.Itoa(limit))
+
+	return c.getMessages(target, "search", q)
+}
+
+func (c *Client) getMessages(target signal.Connect, endpoint string, q url.Values) ([]dto.Message, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if target.IsRoom {
+		u = u.JoinPath("rooms", target.Value, endpoint)
+	} else {
+		u = u.JoinPath("users", target.Value, endpoint)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if target.Password != "" {
+		req.Header.Set(dto.RoomPasswordHeader, target.Password)
+	}
+
+	resp, err := c.send(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	// Servers predating these endpoints have no messages to give us.
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	var res []dto.Message
+	err = json.NewDecoder(resp.Body).Decode(&res)
+
+	return res, err
+}
@@ -0,0 +1,72 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/onfirebyte/chatt/dto"
+)
+
+// PublishKey makes key the public key others encrypt direct messages to us
+// with.
+func (c *Client) PublishKey(key []byte) error {
+	body, err := json.Marshal(dto.PublicKey{Key: key})
+	if err != nil {
+		return err
+	}
+
+	// The body is read on every try, so each one gets its own request.
+	return c.withAuth(true, func(token string) error {
+		req, err := http.NewRequest(http.MethodPut, c.baseURL+"/keys", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode/100 != 2 {
+			return &StatusError{StatusCode: resp.StatusCode}
+		}
+		return nil
+	})
+}
+
+// GetKey returns the public key user published, or ErrNoKey. Servers
+// without encryption support have no keys either.
+func (c *Client) GetKey(user string) ([]byte, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.JoinPath("keys", user).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.send(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNoKey
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	var res dto.PublicKey
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	return res.Key, err
+}
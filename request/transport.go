@@ -0,0 +1,132 @@
+package request
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Options tune how the client reaches the server. The zero value uses the
+// system roots, no client certificate and the proxy from the environment.
+type Options struct {
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string
+	// CertFile and KeyFile hold a client certificate for mutual TLS.
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify accepts any server certificate. Only for testing.
+	InsecureSkipVerify bool
+
+	// Proxy is an http:// or socks5:// URL, with credentials in its user
+	// info if the proxy wants them. When empty, HTTPS_PROXY and HTTP_PROXY
+	// are used. NO_PROXY is honoured either way.
+	Proxy string
+}
+
+// TLSConfig builds the tls.Config the options describe, reading the files.
+func (o Options) TLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA file %s: no certificates found", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, errors.New("a client certificate needs both a cert file and a key file")
+	}
+	if o.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// ProxyFunc picks the proxy for each request, in the form both http.Transport
+// and websocket.Dialer take.
+func (o Options) ProxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	env := httpproxy.FromEnvironment()
+	if o.Proxy != "" {
+		if err := CheckProxy(o.Proxy); err != nil {
+			return nil, err
+		}
+		env.HTTPProxy = o.Proxy
+		env.HTTPSProxy = o.Proxy
+	}
+
+	proxy := env.ProxyFunc()
+	return func(r *http.Request) (*url.URL, error) {
+		return proxy(r.URL)
+	}, nil
+}
+
+// CheckProxy reports whether raw is a proxy URL the client can use.
+func CheckProxy(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "socks5" {
+		return fmt.Errorf("invalid proxy URL %q: scheme must be http or socks5", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid proxy URL %q: missing host", raw)
+	}
+	return nil
+}
+
+// NewClientWithOptions is NewClient with options that apply to both the
+// REST requests and the websockets.
+func NewClientWithOptions(baseURL string, opts Options) (*Client, error) {
+	tlsConfig, err := opts.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := opts.ProxyFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.Proxy = proxy
+
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = tlsConfig
+	dialer.Proxy = proxy
+
+	c := NewClient(baseURL)
+	c.http = &http.Client{Transport: transport}
+	c.dialer = &dialer
+	return c, nil
+}
+
+// wsScheme maps the scheme of the server URL to its websocket counterpart.
+func wsScheme(scheme string) string {
+	switch scheme {
+	case "https", "wss":
+		return "wss"
+	}
+	return "ws"
+}
@@ -0,0 +1,131 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/onfirebyte/chatt/dto"
+	"github.com/onfirebyte/chatt/signal"
+)
+
+// Conn is the websocket of one conversation. Frames are encoded as
+// dto.Envelope when the server advertised the envelope protocol, and as the
+// older plain text frames otherwise.
+type Conn struct {
+	*websocket.Conn
+	Protocol int
+
+	writeMu sync.Mutex
+}
+
+// Send writes one envelope. Plain text servers only understand chat
+// messages, so other types are dropped for them.
+func (c *Conn) Send(t dto.EnvelopeType, payload any) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.Protocol == 0 {
+		msg, ok := payload.(dto.Message)
+		if t != dto.TypeMessage || !ok {
+			return nil
+		}
+		text := msg.Data
+		if msg.Action {
+			text = "/me " + text
+		}
+		return c.WriteMessage(websocket.TextMessage, []byte(text))
+	}
+
+	env, err := dto.NewEnvelope(t, payload)
+	if err != nil {
+		return err
+	}
+	return c.WriteJSON(env)
+}
+
+// Receive reads the next envelope. Frames from plain text servers are
+// wrapped as TypeMessage envelopes.
+func (c *Conn) Receive() (dto.Envelope, error) {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return dto.Envelope{}, err
+	}
+
+	var env dto.Envelope
+	if c.Protocol > 0 {
+		if err := json.Unmarshal(data, &env); err != nil {
+			return dto.Envelope{}, err
+		}
+	}
+	if env.Type == "" {
+		env = dto.Envelope{Type: dto.TypeMessage, Payload: data}
+	}
+	return env, nil
+}
+
+// Dial opens the websocket for the room or direct message described by data.
+func (c *Client) Dial(data signal.Connect) (*Conn, error) {
+	q := url.Values{}
+	q.Set("senderUserName", c.UserName())
+	if data.IsRoom {
+		roomName := data.Value
+		if data.Password != "" {
+			roomName = roomName + ":" + data.Password
+		}
+		q.Set("roomName", url.QueryEscape(roomName))
+	} else {
+		q.Set("recvUserName", url.QueryEscape(data.Value))
+	}
+
+	return c.dial("/ws", q)
+}
+
+// DialEvents opens the control connection, which carries presence for the
+// whole server rather than a single conversation.
+func (c *Client) DialEvents() (*Conn, error) {
+	return c.dial("/events", nil)
+}
+
+func (c *Client) dial(path string, q url.Values) (*Conn, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Scheme = wsScheme(u.Scheme)
+	u.Path = path
+	u.RawQuery = q.Encode()
+
+	var conn *websocket.Conn
+	var resp *http.Response
+	err = c.withAuth(true, func(token string) error {
+		header := http.Header{}
+		header.Set("Authorization", "Bearer "+token)
+		header.Set(dto.ProtocolHeader, strconv.Itoa(dto.ProtocolVersion))
+
+		log.Printf("connecting to %s", u.String())
+		var err error
+		conn, resp, err = c.dialer.Dial(u.String(), header)
+		if err != nil {
+			log.Println("dial error:", err)
+			if errors.Is(err, websocket.ErrBadHandshake) && resp != nil {
+				return &StatusError{StatusCode: resp.StatusCode}
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	protocol, _ := strconv.Atoi(resp.Header.Get(dto.ProtocolHeader))
+	return &Conn{
+		Conn:     conn,
+		Protocol: min(protocol, dto.ProtocolVersion),
+	}, nil
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onfirebyte/chatt/dto"
+)
+
+// A name with maxFailures failed logins within failureWindow is locked out
+// until the window ends.
+const (
+	maxFailures   = 5
+	failureWindow = time.Minute
+)
+
+// session is what an access token stands for.
+type session struct {
+	name    string
+	expires time.Time
+	// refresh is the refresh token the access token was issued with.
+	refresh string
+}
+
+type failures struct {
+	count int
+	since time.Time
+}
+
+// authError is a failed login or registration, sent to clients as a
+// dto.AuthError.
+type authError struct {
+	status     int
+	code       string
+	message    string
+	retryAfter time.Duration
+}
+
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
+	creds, ok := readCredentials(w, r)
+	if !ok {
+		return
+	}
+	token, err := s.register(creds.Name, creds.Password)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, token)
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	creds, ok := readCredentials(w, r)
+	if !ok {
+		return
+	}
+	token, err := s.authenticate(creds.Name, creds.Password)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+	writeJSON(w, token)
+}
+
+// handleRefresh trades a refresh token for a new access token.
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var req dto.Refresh
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid refresh: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	name, ok := s.refresh[req.RefreshToken]
+	var sess dto.Session
+	if ok {
+		sess = s.issue(name, req.RefreshToken)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeAuthError(w, &authError{status: http.StatusUnauthorized, code: dto.AuthInvalidRefresh, message: "refresh token is not valid"})
+		return
+	}
+	writeJSON(w, sess)
+}
+
+// issue creates an access token for name, and a refresh token unless one is
+// given. The caller holds s.mu.
+func (s *Server) issue(name string, refresh string) dto.Session {
+	if refresh == "" {
+		refresh = newToken()
+		s.refresh[refresh] = name
+	}
+
+	sess := &session{name: name, refresh: refresh}
+	expiresIn := 0
+	if s.TokenTTL > 0 {
+		sess.expires = time.Now().Add(s.TokenTTL)
+		expiresIn = int(math.Ceil(s.TokenTTL.Seconds()))
+	}
+	token := newToken()
+	s.tokens[token] = sess
+
+	return dto.Session{
+		Token:        token,
+		RefreshToken: refresh,
+		ExpiresIn:    expiresIn,
+	}
+}
+
+// revoke logs out the session of an access token: the token, its refresh
+// token and every other access token issued with it.
+func (s *Server) revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.tokens[token]
+	if !ok {
+		return
+	}
+	delete(s.tokens, token)
+	delete(s.refresh, sess.refresh)
+	for t, other := range s.tokens {
+		if other.refresh == sess.refresh {
+			delete(s.tokens, t)
+		}
+	}
+}
+
+// register creates the user name and returns its first session.
+func (s *Server) register(name string, password string) (dto.Session, *authError) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.ContainsAny(name, ":/") {
+		return dto.Session{}, &authError{status: http.StatusBadRequest, code: dto.AuthInvalidName, message: "names can't be empty or contain : or /"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[name]; ok {
+		return dto.Session{}, &authError{status: http.StatusConflict, code: dto.AuthNameTaken, message: "name already taken"}
+	}
+	s.users[name] = &user{name: name, password: sha256.Sum256([]byte(password))}
+	log.Printf("registered user %q", name)
+
+	return s.issue(name, ""), nil
+}
+
+// authenticate checks the password of an existing user and returns a fresh
+// session. Repeated failures lock the name out for a while.
+func (s *Server) authenticate(name string, password string) (dto.Session, *authError) {
+	name = strings.TrimSpace(name)
+	hash := sha256.Sum256([]byte(password))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := s.failures[name]
+	if f != nil && time.Since(f.since) >= failureWindow {
+		delete(s.failures, name)
+		f = nil
+	}
+	if f != nil && f.count >= maxFailures {
+		return dto.Session{}, &authError{
+			status:     http.StatusTooManyRequests,
+			code:       dto.AuthRateLimited,
+			message:    "too many failed logins",
+			retryAfter: failureWindow - time.Since(f.since),
+		}
+	}
+
+	u, ok := s.users[name]
+	if !ok {
+		return dto.Session{}, &authError{status: http.StatusNotFound, code: dto.AuthUnknownUser, message: "no such user"}
+	}
+	if subtle.ConstantTimeCompare(u.password[:], hash[:]) != 1 {
+		if f == nil {
+			f = &failures{since: time.Now()}
+			s.failures[name] = f
+		}
+		f.count++
+		return dto.Session{}, &authError{status: http.StatusUnauthorized, code: dto.AuthWrongPassword, message: "wrong password"}
+	}
+	delete(s.failures, name)
+
+	return s.issue(name, ""), nil
+}
+
+// readCredentials decodes the JSON body of a login or registration, writing
+// the error response itself when it can't.
+func readCredentials(w http.ResponseWriter, r *http.Request) (dto.Credentials, bool) {
+	var creds dto.Credentials
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return creds, false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&creds); err != nil {
+		http.Error(w, fmt.Sprintf("invalid credentials: %v", err), http.StatusBadRequest)
+		return creds, false
+	}
+	return creds, true
+}
+
+func writeAuthError(w http.ResponseWriter, err *authError) {
+	if err.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.retryAfter.Seconds()))))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.status)
+	json.NewEncoder(w).Encode(dto.AuthError{Code: err.code, Message: err.message})
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onfirebyte/chatt/dto"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// handleRoomHistory serves GET /rooms/{name}/messages and
+// /rooms/{name}/search. Locked rooms need the room password in the
+// X-Room-Password header.
+func (s *Server) handleRoomHistory(w http.ResponseWriter, r *http.Request) {
+	name, endpoint, ok := historyPath(r, "/rooms/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if _, ok := s.authorize(r); !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	query, err := historyQuery(r, endpoint)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	rm, ok := s.rooms[name]
+	if ok && !rm.checkPassword(r.Header.Get(dto.RoomPasswordHeader)) {
+		s.mu.Unlock()
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	var page []dto.Message
+	if ok {
+		page = historyPage(rm.history, r, query)
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, page)
+}
+
+// handleDirectHistory serves GET /users/{name}/messages and
+// /users/{name}/search, the direct messages between the caller and name.
+func (s *Server) handleDirectHistory(w http.ResponseWriter, r *http.Request) {
+	recv, endpoint, ok := historyPath(r, "/users/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	sender, ok := s.authorize(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	query, err := historyQuery(r, endpoint)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	var page []dto.Message
+	if rm, ok := s.dms[dmKey(sender, recv)]; ok {
+		page = historyPage(rm.history, r, query)
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, page)
+}
+
+// historyPath extracts {name} and the endpoint from GET
+// {prefix}{name}/messages or {prefix}{name}/search.
+func historyPath(r *http.Request, prefix string) (string, string, bool) {
+	if r.Method != http.MethodGet {
+		return "", "", false
+	}
+	rest := strings.TrimPrefix(r.URL.Path, prefix)
+	name, endpoint, ok := strings.Cut(rest, "/")
+	if !ok || name == "" || (endpoint != "messages" && endpoint != "search") {
+		return "", "", false
+	}
+	return name, endpoint, true
+}
+
+// historyQuery returns the pattern a search endpoint filters with: q is
+// matched as a case-insensitive substring, or as a regular expression when
+// regex=1. It returns nil for the messages endpoint.
+func historyQuery(r *http.Request, endpoint string) (*regexp.Regexp, error) {
+	if endpoint != "search" {
+		return nil, nil
+	}
+
+	q := r.URL.Query()
+	pattern := q.Get("q")
+	if q.Get("regex") != "1" {
+		pattern = "(?i)" + regexp.QuoteMeta(pattern)
+	}
+	return regexp.Compile(pattern)
+}
+
+// historyPage returns up to limit messages older than before, oldest first.
+// When before_id names a message still in history, the page ends right
+// before it instead, so messages sharing its timestamp aren't skipped. With
+// a query only the messages matching it count.
+func historyPage(history []dto.Message, r *http.Request, query *regexp.Regexp) []dto.Message {
+	q := r.URL.Query()
+
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	limit = min(limit, maxHistoryLimit)
+
+	end := len(history)
+	idx := -1
+	if id := q.Get("before_id"); id != "" {
+		idx = slices.IndexFunc(history, func(m dto.Message) bool {
+			return m.ID == id
+		})
+	}
+	if idx >= 0 {
+		end = idx
+	} else if before, err := time.Parse(time.RFC3339Nano, q.Get("before")); err == nil {
+		end = sort.Search(len(history), func(i int) bool {
+			return !history[i].Timestamp.Before(before)
+		})
+	}
+
+	var page []dto.Message
+	if query == nil {
+		start := max(end-limit, 0)
+		page = make([]dto.Message, end-start)
+		copy(page, history[start:end])
+	} else {
+		for i := end - 1; i >= 0 && len(page) < limit; i-- {
+			if !history[i].Deleted && query.MatchString(history[i].Data) {
+				page = append(page, history[i])
+			}
+		}
+		slices.Reverse(page)
+	}
+
+	// Reactions keep changing after we let go of the lock.
+	for i, msg := range page {
+		if msg.Reactions == nil {
+			continue
+		}
+		reactions := make(map[string][]string, len(msg.Reactions))
+		for emoji, users := range msg.Reactions {
+			reactions[emoji] = slices.Clone(users)
+		}
+		page[i].Reactions = reactions
+	}
+	return page
+}
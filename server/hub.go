@@ -0,0 +1,238 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/onfirebyte/chatt/dto"
+)
+
+const (
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+	sendBuffer = 64
+	maxHistory = 1000
+
+	maxTopicLength = 200
+)
+
+// room is a conversation, either a named room or a direct message between
+// two users. All fields are guarded by Server.mu.
+type room struct {
+	id       string
+	name     string
+	password [sha256.Size]byte
+	hasPass  bool
+	topic    string
+	clients  map[*client]struct{}
+	history  []dto.Message
+}
+
+func (rm *room) locked() bool {
+	return rm.hasPass
+}
+
+func (rm *room) checkPassword(password string) bool {
+	hash := sha256.Sum256([]byte(password))
+	return !rm.hasPass || subtle.ConstantTimeCompare(rm.password[:], hash[:]) == 1
+}
+
+type client struct {
+	user     string
+	conn     *websocket.Conn
+	send     chan []byte
+	protocol int
+}
+
+func dmKey(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + "\x00" + b
+}
+
+// joinRoom returns the room called name, creating it with password if it
+// doesn't exist yet.
+func (s *Server) joinRoom(name, password string) (*room, int) {
+	if name == "" {
+		return nil, http.StatusBadRequest
+	}
+
+	hash := sha256.Sum256([]byte(password))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rm, ok := s.rooms[name]
+	if !ok {
+		rm = &room{
+			id:       "room/" + name,
+			name:     name,
+			password: hash,
+			hasPass:  password != "",
+			clients:  map[*client]struct{}{},
+		}
+		s.rooms[name] = rm
+		log.Printf("created room %q", name)
+		return rm, http.StatusOK
+	}
+
+	if !rm.checkPassword(password) {
+		return nil, http.StatusForbidden
+	}
+	return rm, http.StatusOK
+}
+
+func (s *Server) directRoom(sender, recv string) (*room, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[recv]; !ok {
+		return nil, http.StatusNotFound
+	}
+
+	key := dmKey(sender, recv)
+	rm, ok := s.dms[key]
+	if !ok {
+		rm = &room{
+			id:      "dm/" + strings.ReplaceAll(key, "\x00", "/"),
+			clients: map[*client]struct{}{},
+		}
+		s.dms[key] = rm
+	}
+	return rm, http.StatusOK
+}
+
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.authorize(r)
+	q := r.URL.Query()
+	if !ok || name != q.Get("senderUserName") {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	// The client escapes these values once more on top of the query encoding.
+	var rm *room
+	status := http.StatusBadRequest
+	if v := q.Get("roomName"); v != "" {
+		if roomName, err := url.QueryUnescape(v); err == nil {
+			roomName, password, _ := strings.Cut(roomName, ":")
+			rm, status = s.joinRoom(roomName, password)
+		}
+	} else if v := q.Get("recvUserName"); v != "" {
+		if recv, err := url.QueryUnescape(v); err == nil {
+			rm, status = s.directRoom(name, recv)
+		}
+	}
+	if rm == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	c, err := s.upgrade(w, r, name)
+	if err != nil {
+		log.Println("upgrade:", err)
+		return
+	}
+
+	s.mu.Lock()
+	rm.clients[c] = struct{}{}
+	s.welcome(rm, c)
+	s.broadcastEvent(rm, dto.TypeJoin, dto.Member{User: name, Timestamp: time.Now().UTC()})
+	s.mu.Unlock()
+
+	go c.writePump()
+	s.readPump(c, rm)
+}
+
+// upgrade switches r to a websocket for user, negotiating the envelope
+// protocol version.
+func (s *Server) upgrade(w http.ResponseWriter, r *http.Request, user string) (*client, error) {
+	protocol, _ := strconv.Atoi(r.Header.Get(dto.ProtocolHeader))
+
+	header := http.Header{}
+	header.Set(dto.ProtocolHeader, strconv.Itoa(dto.ProtocolVersion))
+	conn, err := s.upgrader.Upgrade(w, r, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client{
+		user:     user,
+		conn:     conn,
+		send:     make(chan []byte, sendBuffer),
+		protocol: min(protocol, dto.ProtocolVersion),
+	}, nil
+}
+
+func (s *Server) readPump(c *client, rm *room) {
+	defer func() {
+		s.mu.Lock()
+		if _, ok := rm.clients[c]; ok {
+			delete(rm.clients, c)
+			close(c.send)
+		}
+		s.broadcastEvent(rm, dto.TypeLeave, dto.Member{User: c.user, Timestamp: time.Now().UTC()})
+		s.mu.Unlock()
+	}()
+
+	c.readLoop(func(data []byte) {
+		s.handleFrame(c, rm, data)
+	})
+}
+
+// readLoop calls handle for every frame until the connection fails.
+func (c *client) readLoop(handle func([]byte)) {
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Println("read:", err)
+			}
+			return
+		}
+
+		handle(data)
+	}
+}
+
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/onfirebyte/chatt/dto"
+)
+
+const (
+	// keySize is the length of an X25519 public key.
+	keySize = 32
+	// sealOverhead is what a box adds to its message: a 24 byte nonce, a
+	// 16 byte authenticator and room for the sender, recipient and message
+	// ID sealed along with the text.
+	sealOverhead = 24 + 16 + 512
+)
+
+// handleKeys serves PUT /keys, which publishes the caller's public key, and
+// GET /keys/{name}. The server only passes keys along; it never sees what
+// they encrypt.
+func (s *Server) handleKeys(w http.ResponseWriter, r *http.Request) {
+	caller, ok := s.authorize(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/keys"), "/")
+	switch {
+	case r.Method == http.MethodPut && name == "":
+		var key dto.PublicKey
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&key); err != nil || len(key.Key) != keySize {
+			http.Error(w, "want a 32 byte key", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		s.keys[caller] = key.Key
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && name != "" && !strings.Contains(name, "/"):
+		s.mu.Lock()
+		key, ok := s.keys[name]
+		s.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, dto.PublicKey{Key: key})
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
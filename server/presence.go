@@ -0,0 +1,98 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/onfirebyte/chatt/dto"
+)
+
+// handleEvents serves the control connection. A user is online while they
+// have one open, and the server pushes every presence change over it.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.authorize(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	c, err := s.upgrade(w, r, name)
+	if err != nil {
+		log.Println("upgrade:", err)
+		return
+	}
+	// The control connection has no plain text fallback.
+	c.protocol = dto.ProtocolVersion
+
+	s.mu.Lock()
+	s.control.clients[c] = struct{}{}
+	for user := range s.users {
+		if env, ok := s.envelope(s.control, dto.TypePresence, dto.Presence{User: user, Status: s.status(user)}); ok {
+			s.sendTo(s.control, c, env)
+		}
+	}
+	s.online[name]++
+	if s.online[name] == 1 {
+		s.broadcastEvent(s.control, dto.TypePresence, dto.Presence{User: name, Status: s.status(name)})
+	}
+	s.mu.Unlock()
+
+	go c.writePump()
+
+	defer func() {
+		s.mu.Lock()
+		if _, ok := s.control.clients[c]; ok {
+			delete(s.control.clients, c)
+			close(c.send)
+		}
+		s.online[name]--
+		if s.online[name] == 0 {
+			delete(s.online, name)
+			delete(s.away, name)
+			s.broadcastEvent(s.control, dto.TypePresence, dto.Presence{User: name, Status: dto.Offline})
+		}
+		s.mu.Unlock()
+	}()
+
+	c.readLoop(func(data []byte) {
+		s.handleControlFrame(c, data)
+	})
+}
+
+func (s *Server) handleControlFrame(c *client, data []byte) {
+	env, ok := s.decode(c, s.control, data)
+	if !ok {
+		return
+	}
+
+	switch env.Type {
+	case dto.TypePresence:
+		var p dto.Presence
+		if err := env.Decode(&p); err != nil || (p.Status != dto.Online && p.Status != dto.Away) {
+			s.reject(c, s.control, "bad_payload", "presence must be online or away")
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.away[c.user] == (p.Status == dto.Away) {
+			return
+		}
+		s.away[c.user] = p.Status == dto.Away
+		s.broadcastEvent(s.control, dto.TypePresence, dto.Presence{User: c.user, Status: p.Status})
+	default:
+		s.reject(c, s.control, "unsupported", "only presence is accepted on the control connection")
+	}
+}
+
+// status returns the presence of user. s.mu must be held.
+func (s *Server) status(user string) dto.PresenceStatus {
+	switch {
+	case s.online[user] == 0:
+		return dto.Offline
+	case s.away[user]:
+		return dto.Away
+	default:
+		return dto.Online
+	}
+}
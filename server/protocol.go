@@ -0,0 +1,379 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/onfirebyte/chatt/dto"
+)
+
+// handleFrame processes one frame read from c. Clients that didn't negotiate
+// the envelope protocol send their messages as plain text.
+func (s *Server) handleFrame(c *client, rm *room, data []byte) {
+	if c.protocol == 0 {
+		s.postMessage(c, rm, dto.Message{Data: string(data)})
+		return
+	}
+
+	env, ok := s.decode(c, rm, data)
+	if !ok {
+		return
+	}
+
+	switch env.Type {
+	case dto.TypeMessage:
+		var msg dto.Message
+		if err := env.Decode(&msg); err != nil {
+			s.reject(c, rm, "bad_payload", "message payload is invalid")
+			return
+		}
+		s.postMessage(c, rm, msg)
+	case dto.TypeEdit, dto.TypeDelete:
+		var msg dto.Message
+		if err := env.Decode(&msg); err != nil || msg.ID == "" {
+			s.reject(c, rm, "bad_payload", "edit payload needs a message id")
+			return
+		}
+		s.changeMessage(c, rm, env.Type, msg)
+	case dto.TypeReact:
+		var reaction dto.Reaction
+		if err := env.Decode(&reaction); err != nil || reaction.ID == "" || !validEmoji(reaction.Emoji) {
+			s.reject(c, rm, "bad_payload", "reaction needs a message id and an emoji")
+			return
+		}
+		s.react(c, rm, reaction)
+	case dto.TypeTopic:
+		var topic dto.Topic
+		if err := env.Decode(&topic); err != nil {
+			s.reject(c, rm, "bad_payload", "topic payload is invalid")
+			return
+		}
+		s.setTopic(c, rm, topic.Text)
+	case dto.TypeTyping:
+		var typing dto.Typing
+		if err := env.Decode(&typing); err != nil {
+			s.reject(c, rm, "bad_payload", "typing payload is invalid")
+			return
+		}
+		typing.User = c.user
+
+		s.mu.Lock()
+		s.relay(rm, c, dto.TypeTyping, typing)
+		s.mu.Unlock()
+	default:
+		s.reject(c, rm, "unsupported", fmt.Sprintf("unsupported envelope type %q", env.Type))
+	}
+}
+
+func (s *Server) decode(c *client, rm *room, data []byte) (dto.Envelope, bool) {
+	var env dto.Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		s.reject(c, rm, "bad_frame", "frame is not a valid envelope")
+		return env, false
+	}
+	return env, true
+}
+
+// postMessage adds a message from c to rm. Only Data, Parent, Action and
+// Sealed are taken from post. A non-empty Parent makes it a reply; replies
+// to replies join the thread of the original message.
+func (s *Server) postMessage(c *client, rm *room, post dto.Message) {
+	text := strings.TrimSpace(post.Data)
+	parent := post.Parent
+	if text == "" {
+		return
+	}
+	if !s.checkText(c, rm, text, post.Sealed) {
+		return
+	}
+
+	s.mu.Lock()
+	if parent != "" {
+		idx := slices.IndexFunc(rm.history, func(m dto.Message) bool {
+			return m.ID == parent
+		})
+		if idx < 0 || rm.history[idx].Deleted {
+			s.mu.Unlock()
+			s.reject(c, rm, "not_found", "the message you replied to no longer exists")
+			return
+		}
+		if root := rm.history[idx].Parent; root != "" {
+			parent = root
+		}
+	}
+	defer s.mu.Unlock()
+
+	id := s.nextID()
+	msg := dto.Message{
+		ID:        id,
+		User:      c.user,
+		Data:      text,
+		Timestamp: time.Now().UTC(),
+		Parent:    parent,
+		Action:    post.Action,
+		Sealed:    post.Sealed,
+	}
+	legacy, err := json.Marshal(msg)
+	if err != nil {
+		log.Println("encode message:", err)
+		return
+	}
+
+	rm.history = append(rm.history, msg)
+	if len(rm.history) > maxHistory {
+		rm.history = rm.history[len(rm.history)-maxHistory:]
+	}
+
+	env, ok := s.encode(rm, id, dto.TypeMessage, msg)
+	if !ok {
+		return
+	}
+	for cl := range rm.clients {
+		if cl.protocol == 0 {
+			s.sendTo(rm, cl, legacy)
+		} else {
+			s.sendTo(rm, cl, env)
+		}
+	}
+}
+
+// changeMessage applies an edit or delete from c to one of its own messages
+// and tells the room about it.
+func (s *Server) changeMessage(c *client, rm *room, t dto.EnvelopeType, change dto.Message) {
+	text := strings.TrimSpace(change.Data)
+	if t == dto.TypeEdit && text == "" {
+		s.reject(c, rm, "bad_payload", "edited message can't be empty")
+		return
+	}
+	if t == dto.TypeEdit && !s.checkText(c, rm, text, change.Sealed) {
+		return
+	}
+
+	s.mu.Lock()
+	idx := slices.IndexFunc(rm.history, func(m dto.Message) bool {
+		return m.ID == change.ID
+	})
+	// Old messages fall out of the history, so a missing ID isn't
+	// necessarily someone else's message.
+	if idx < 0 || rm.history[idx].Deleted {
+		s.mu.Unlock()
+		s.reject(c, rm, "not_found", "that message is no longer available")
+		return
+	}
+	if rm.history[idx].User != c.user {
+		s.mu.Unlock()
+		s.reject(c, rm, "forbidden", "you can only change your own messages")
+		return
+	}
+	defer s.mu.Unlock()
+
+	msg := &rm.history[idx]
+	if t == dto.TypeDelete {
+		msg.Data = ""
+		msg.Sealed = false
+		msg.Deleted = true
+	} else {
+		msg.Data = text
+		msg.Sealed = change.Sealed
+		msg.Edited = true
+	}
+	s.broadcastEvent(rm, t, *msg)
+}
+
+// checkText rejects a message text that is too long. Sealed text is the
+// base64 of a box, so it is measured against the longest box the limit
+// allows; only direct messages can be sealed.
+func (s *Server) checkText(c *client, rm *room, text string, sealed bool) bool {
+	if sealed && rm.name != "" {
+		s.reject(c, rm, "unsupported", "only direct messages can be encrypted")
+		return false
+	}
+
+	limit := s.limits.MaxMessageLength
+	if limit <= 0 {
+		return true
+	}
+	length := utf8.RuneCountInString(text)
+	if sealed {
+		limit = base64.StdEncoding.EncodedLen(sealOverhead + utf8.UTFMax*limit)
+	}
+	if length > limit {
+		s.reject(c, rm, "too_long", fmt.Sprintf("messages are limited to %d characters", s.limits.MaxMessageLength))
+		return false
+	}
+	return true
+}
+
+// react toggles the reaction of c on a message of rm.
+func (s *Server) react(c *client, rm *room, reaction dto.Reaction) {
+	s.mu.Lock()
+	idx := slices.IndexFunc(rm.history, func(m dto.Message) bool {
+		return m.ID == reaction.ID
+	})
+	if idx < 0 || rm.history[idx].Deleted {
+		s.mu.Unlock()
+		s.reject(c, rm, "not_found", "message not found")
+		return
+	}
+	defer s.mu.Unlock()
+
+	msg := &rm.history[idx]
+	if msg.Reactions == nil {
+		msg.Reactions = map[string][]string{}
+	}
+	users := msg.Reactions[reaction.Emoji]
+	if i := slices.Index(users, c.user); i >= 0 {
+		users = slices.Delete(users, i, i+1)
+	} else {
+		users = append(users, c.user)
+	}
+	if len(users) == 0 {
+		delete(msg.Reactions, reaction.Emoji)
+	} else {
+		msg.Reactions[reaction.Emoji] = users
+	}
+
+	s.broadcastEvent(rm, dto.TypeReact, dto.Reaction{ID: msg.ID, Reactions: msg.Reactions})
+}
+
+// setTopic changes the topic of rm and tells everyone in it. Direct messages
+// don't have topics.
+func (s *Server) setTopic(c *client, rm *room, text string) {
+	text = strings.Join(strings.Fields(text), " ")
+	if rm.name == "" {
+		s.reject(c, rm, "unsupported", "direct messages don't have a topic")
+		return
+	}
+	if utf8.RuneCountInString(text) > maxTopicLength {
+		s.reject(c, rm, "too_long", fmt.Sprintf("topics are limited to %d characters", maxTopicLength))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rm.topic = text
+	s.broadcastEvent(rm, dto.TypeTopic, dto.Topic{Text: text, User: c.user, Timestamp: time.Now().UTC()})
+}
+
+// validEmoji accepts a short string without spaces; we don't try to tell
+// emoji apart from other symbols.
+func validEmoji(emoji string) bool {
+	return emoji != "" && len(emoji) <= 32 && !strings.ContainsFunc(emoji, unicode.IsSpace)
+}
+
+// broadcastEvent sends an event to every client of rm that speaks the
+// envelope protocol. s.mu must be held.
+func (s *Server) broadcastEvent(rm *room, t dto.EnvelopeType, payload any) {
+	env, ok := s.envelope(rm, t, payload)
+	if !ok {
+		return
+	}
+	for c := range rm.clients {
+		if c.protocol > 0 {
+			s.sendTo(rm, c, env)
+		}
+	}
+}
+
+// relay sends an event to every envelope client of rm except from. s.mu
+// must be held.
+func (s *Server) relay(rm *room, from *client, t dto.EnvelopeType, payload any) {
+	env, ok := s.envelope(rm, t, payload)
+	if !ok {
+		return
+	}
+	for c := range rm.clients {
+		if c != from && c.protocol > 0 {
+			s.sendTo(rm, c, env)
+		}
+	}
+}
+
+// welcome greets a client that just joined rm. s.mu must be held.
+func (s *Server) welcome(rm *room, c *client) {
+	if c.protocol == 0 {
+		return
+	}
+
+	if env, ok := s.envelope(rm, dto.TypeHello, dto.Hello{Limits: s.limits, Topic: rm.topic}); ok {
+		s.sendTo(rm, c, env)
+	}
+
+	text := "You are now chatting privately"
+	if rm.name != "" {
+		text = fmt.Sprintf("Welcome to %s, %d connected", rm.name, len(rm.clients))
+	}
+	if env, ok := s.envelope(rm, dto.TypeSystem, dto.Notice{Text: text, Timestamp: time.Now().UTC()}); ok {
+		s.sendTo(rm, c, env)
+	}
+}
+
+// reject tells c its last frame was refused, with code in the notice.
+func (s *Server) reject(c *client, rm *room, code string, text string) {
+	if c.protocol == 0 {
+		return
+	}
+
+	env, err := dto.NewEnvelope(dto.TypeError, dto.Notice{Text: text, Timestamp: time.Now().UTC(), Code: code})
+	if err != nil {
+		return
+	}
+	env.Conversation = rm.id
+	data, err := json.Marshal(env)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := rm.clients[c]; ok {
+		s.sendTo(rm, c, data)
+	}
+}
+
+// envelope encodes payload for rm with a fresh ID. s.mu must be held.
+func (s *Server) envelope(rm *room, t dto.EnvelopeType, payload any) ([]byte, bool) {
+	return s.encode(rm, s.nextID(), t, payload)
+}
+
+func (s *Server) encode(rm *room, id string, t dto.EnvelopeType, payload any) ([]byte, bool) {
+	env, err := dto.NewEnvelope(t, payload)
+	if err != nil {
+		log.Println("encode envelope:", err)
+		return nil, false
+	}
+
+	env.ID = id
+	env.Conversation = rm.id
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		log.Println("encode envelope:", err)
+		return nil, false
+	}
+	return data, true
+}
+
+// nextID returns a server-wide unique ID. s.mu must be held.
+func (s *Server) nextID() string {
+	s.lastID++
+	return strconv.FormatUint(s.lastID, 10)
+}
+
+// sendTo queues data for c, dropping c if it can't keep up. s.mu must be held.
+func (s *Server) sendTo(rm *room, c *client, data []byte) {
+	select {
+	case c.send <- data:
+	default:
+		delete(rm.clients, c)
+		close(c.send)
+	}
+}
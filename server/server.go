@@ -0,0 +1,205 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/onfirebyte/chatt/dto"
+)
+
+// DefaultTokenTTL is how long access tokens last unless TokenTTL is set.
+const DefaultTokenTTL = time.Hour
+
+// Server is an in-memory reference implementation of the chatt protocol.
+// Everything is lost when the process exits.
+type Server struct {
+	// TokenTTL is how long an access token lasts before clients have to
+	// refresh it. Zero never expires them. Refresh tokens last until logout.
+	TokenTTL time.Duration
+
+	mu     sync.Mutex
+	users  map[string]*user
+	tokens map[string]*session
+	rooms  map[string]*room
+	dms    map[string]*room
+	lastID uint64
+	limits dto.Limits
+
+	// refresh maps refresh tokens to the user they were issued to;
+	// failures counts recent failed logins per user name.
+	refresh  map[string]string
+	failures map[string]*failures
+
+	// keys holds the public key each user published for encrypted direct
+	// messages.
+	keys map[string][]byte
+
+	// control holds the control connections; online counts them per user.
+	control *room
+	online  map[string]int
+	away    map[string]bool
+
+	upgrader websocket.Upgrader
+}
+
+type user struct {
+	name     string
+	password [sha256.Size]byte
+}
+
+func New() *Server {
+	return &Server{
+		TokenTTL: DefaultTokenTTL,
+
+		users:  map[string]*user{},
+		tokens: map[string]*session{},
+		rooms:  map[string]*room{},
+		dms:    map[string]*room{},
+		limits: dto.Limits{MaxMessageLength: 4000},
+
+		refresh:  map[string]string{},
+		failures: map[string]*failures{},
+		keys:     map[string][]byte{},
+
+		control: &room{id: "control", clients: map[*client]struct{}{}},
+		online:  map[string]int{},
+		away:    map[string]bool{},
+	}
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	log.Printf("chatt server listening on %s", addr)
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+// ListenAndServeTLS is ListenAndServe over https and wss.
+func (s *Server) ListenAndServeTLS(addr string, certFile string, keyFile string) error {
+	log.Printf("chatt server listening on %s with TLS", addr)
+	return http.ListenAndServeTLS(addr, certFile, keyFile, s.Handler())
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", s.handleUsers)
+	mux.HandleFunc("/me", s.handleMe)
+	mux.HandleFunc("/login", s.handleLogin)
+	mux.HandleFunc("/register", s.handleRegister)
+	mux.HandleFunc("/refresh", s.handleRefresh)
+	mux.HandleFunc("/keys", s.handleKeys)
+	mux.HandleFunc("/keys/", s.handleKeys)
+	mux.HandleFunc("/rooms", s.handleRooms)
+	mux.HandleFunc("/rooms/", s.handleRoomHistory)
+	mux.HandleFunc("/users/", s.handleDirectHistory)
+	mux.HandleFunc("/ws", s.handleWS)
+	mux.HandleFunc("/events", s.handleEvents)
+	return mux
+}
+
+func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		names := make([]string, 0, len(s.users))
+		for name := range s.users {
+			names = append(names, name)
+		}
+		s.mu.Unlock()
+
+		sort.Strings(names)
+		writeJSON(w, names)
+	case http.MethodPost:
+		// Old clients logged in here with the password in the query
+		// string, where it ends up in access logs.
+		if r.URL.Query().Has("password") {
+			http.Error(w, "send credentials in the body of POST /login or /register", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMe tells clients who their token belongs to, so a saved session can
+// be checked, and revokes the session on DELETE.
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.authorize(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, dto.User{Name: name})
+	case http.MethodDelete:
+		s.revoke(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleRooms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	rooms := make([]dto.Room, 0, len(s.rooms))
+	for _, rm := range s.rooms {
+		rooms = append(rooms, dto.Room{Name: rm.name, Lock: rm.locked()})
+	}
+	s.mu.Unlock()
+
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].Name < rooms[j].Name
+	})
+	writeJSON(w, rooms)
+}
+
+// authorize returns the user name that owns the bearer token in r. Expired
+// tokens are forgotten.
+func (s *Server) authorize(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.tokens[token]
+	if !ok {
+		return "", false
+	}
+	if !sess.expires.IsZero() && time.Now().After(sess.expires) {
+		delete(s.tokens, token)
+		return "", false
+	}
+	return sess.name, true
+}
+
+func newToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("encode response:", err)
+	}
+}
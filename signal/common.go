@@ -8,8 +8,21 @@ type Result[U any] struct {
 type UserInfo struct {
 	Name  string
 	Token string
+	// RefreshToken is empty on servers whose tokens don't expire.
+	RefreshToken string
 }
 
+// Logout returns to the login screen once the session has been dropped.
+type Logout struct{}
+
+// SessionExpired is sent when the server rejected the token and it could
+// not be refreshed; requests wait until the user logs in again.
+type SessionExpired struct{}
+
+// Reauthenticated is sent once the user logged in again after
+// SessionExpired.
+type Reauthenticated struct{}
+
 type Size struct {
 	Width  int
 	Height int
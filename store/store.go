@@ -0,0 +1,283 @@
+// Package store keeps chat messages on disk so conversations survive a
+// restart and can be read offline.
+//
+// Messages live under the XDG data directory, in one JSON lines file per
+// conversation at chatt/{server}/{user}/{conversation}.jsonl. Files are only
+// appended to: an edit, delete or reaction is stored by appending the changed
+// message, and the last line for a message ID wins.
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/onfirebyte/chatt/dto"
+)
+
+// DefaultLimit is how many messages are kept per conversation.
+const DefaultLimit = 5000
+
+const ext = ".jsonl"
+
+// Store holds the conversations of one user on one server.
+type Store struct {
+	dir   string
+	limit int
+
+	mu sync.Mutex
+}
+
+// Account is a server and user that have stored conversations.
+type Account struct {
+	Server string
+	User   string
+}
+
+// Dir returns the directory every store lives in.
+func Dir() (string, error) {
+	base := os.Getenv("XDG_DATA_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(base, "chatt"), nil
+}
+
+// ServerName turns a server URL into the name of its directory.
+func ServerName(rawURL string) string {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return strings.NewReplacer(":", "_", "/", "_", `\`, "_").Replace(host)
+}
+
+// AccountDir returns the directory of user on server. Besides the stored
+// conversations it holds the user's other local state, such as keys.
+func AccountDir(server string, user string) (string, error) {
+	root, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, ServerName(server), url.PathEscape(user)), nil
+}
+
+// Open returns the store of user on server, keeping at most limit messages
+// per conversation; zero keeps everything.
+func Open(server string, user string, limit int) (*Store, error) {
+	dir, err := AccountDir(server, user)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, limit: limit}, nil
+}
+
+// Accounts lists every server and user with a store.
+func Accounts() ([]Account, error) {
+	root, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	servers, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := []Account{}
+	for _, server := range servers {
+		if !server.IsDir() {
+			continue
+		}
+		users, err := os.ReadDir(filepath.Join(root, server.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			name, err := url.PathUnescape(user.Name())
+			if user.IsDir() && err == nil {
+				res = append(res, Account{Server: server.Name(), User: name})
+			}
+		}
+	}
+	return res, nil
+}
+
+// Conversations lists the keys of the stored conversations.
+func (s *Store) Conversations() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []string{}
+	for _, v := range entries {
+		name, ok := strings.CutSuffix(v.Name(), ext)
+		if !ok || v.IsDir() {
+			continue
+		}
+		if key, err := url.PathUnescape(name); err == nil {
+			res = append(res, key)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func (s *Store) path(conversation string) string {
+	return filepath.Join(s.dir, url.PathEscape(conversation)+ext)
+}
+
+// Append adds messages to a conversation.
+func (s *Store) Append(conversation string, msgs ...dto.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path(conversation), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	for _, v := range msgs {
+		if err := enc.Encode(v); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// Load returns the latest messages of a conversation, oldest first. It only
+// reads the file; Compact is what trims it.
+func (s *Store) Load(conversation string) ([]dto.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, _, err := s.read(conversation)
+	return res, err
+}
+
+// Compact rewrites a conversation file that grew past the limit with only
+// the messages Load returns.
+func (s *Store) Compact(conversation string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res, lines, err := s.read(conversation)
+	if err != nil || s.limit <= 0 || lines <= s.limit {
+		return err
+	}
+	return s.rewrite(conversation, res)
+}
+
+// read returns the kept messages of a conversation and how many lines its
+// file has. s.mu must be held.
+func (s *Store) read(conversation string) ([]dto.Message, int, error) {
+	f, err := os.Open(s.path(conversation))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	type messageKey struct {
+		user      string
+		data      string
+		timestamp int64
+	}
+
+	res := []dto.Message{}
+	byID := map[string]int{}
+	seen := map[messageKey]bool{}
+	lines := 0
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines++
+		var msg dto.Message
+		// A line cut short by a crash shouldn't cost the whole file.
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+
+		if msg.ID != "" {
+			if i, ok := byID[msg.ID]; ok {
+				res[i] = msg
+				continue
+			}
+			byID[msg.ID] = len(res)
+		} else {
+			k := messageKey{msg.User, msg.Data, msg.Timestamp.UnixNano()}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		res = append(res, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Timestamp.Before(res[j].Timestamp)
+	})
+	if s.limit > 0 && len(res) > s.limit {
+		res = res[len(res)-s.limit:]
+	}
+	return res, lines, nil
+}
+
+// rewrite replaces a conversation file with msgs. s.mu must be held.
+func (s *Store) rewrite(conversation string, msgs []dto.Message) error {
+	tmp, err := os.CreateTemp(s.dir, ".compact-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, v := range msgs {
+		if err := enc.Encode(v); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(conversation))
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"bufio"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/onfirebyte/chatt/dto"
+)
+
+func open(t *testing.T, limit int) *Store {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	s, err := Open("http://localhost:8080", "alice", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+	}
+	return n
+}
+
+func TestLoad(t *testing.T) {
+	at := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	msg := func(id string, data string, minute int) dto.Message {
+		return dto.Message{ID: id, User: "bob", Data: data, Timestamp: at.Add(time.Duration(minute) * time.Minute)}
+	}
+
+	tests := []struct {
+		name   string
+		limit  int
+		append []dto.Message
+		want   []string
+	}{
+		{
+			name:   "empty",
+			append: nil,
+			want:   nil,
+		},
+		{
+			name:   "sorted by time",
+			append: []dto.Message{msg("2", "b", 2), msg("1", "a", 1)},
+			want:   []string{"a", "b"},
+		},
+		{
+			name:   "last line for an ID wins",
+			append: []dto.Message{msg("1", "a", 1), msg("2", "b", 2), msg("1", "edited", 1)},
+			want:   []string{"edited", "b"},
+		},
+		{
+			name:   "messages without ID are stored once",
+			append: []dto.Message{msg("", "a", 1), msg("", "a", 1), msg("", "a", 2)},
+			want:   []string{"a", "a"},
+		},
+		{
+			name:   "keeps the latest within the limit",
+			limit:  2,
+			append: []dto.Message{msg("1", "a", 1), msg("2", "b", 2), msg("3", "c", 3)},
+			want:   []string{"b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := open(t, tt.limit)
+			if err := s.Append("#general", tt.append...); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := s.Load("#general")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d messages, want %d", len(got), len(tt.want))
+			}
+			for i, v := range got {
+				if v.Data != tt.want[i] {
+					t.Errorf("message %d is %q, want %q", i, v.Data, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCompact(t *testing.T) {
+	at := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	msgs := []dto.Message{}
+	for i := 0; i < 5; i++ {
+		msgs = append(msgs, dto.Message{ID: string(rune('a' + i)), User: "bob", Timestamp: at.Add(time.Duration(i) * time.Minute)})
+	}
+
+	tests := []struct {
+		name      string
+		limit     int
+		wantLines int
+	}{
+		{name: "past the limit", limit: 3, wantLines: 3},
+		{name: "within the limit", limit: 5, wantLines: 5},
+		{name: "no limit", limit: 0, wantLines: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := open(t, tt.limit)
+			if err := s.Append("@bob", msgs...); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := s.Load("@bob"); err != nil {
+				t.Fatal(err)
+			}
+			if n := countLines(t, s.path("@bob")); n != len(msgs) {
+				t.Fatalf("Load left %d lines, want the %d appended", n, len(msgs))
+			}
+
+			if err := s.Compact("@bob"); err != nil {
+				t.Fatal(err)
+			}
+			if n := countLines(t, s.path("@bob")); n != tt.wantLines {
+				t.Errorf("Compact left %d lines, want %d", n, tt.wantLines)
+			}
+			got, err := s.Load("@bob")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if last := got[len(got)-1].ID; last != "e" {
+				t.Errorf("latest message is %q, want %q", last, "e")
+			}
+		})
+	}
+}
+
+func TestConversations(t *testing.T) {
+	s := open(t, 0)
+	for _, key := range []string{"@bob", "#general", "#a/b"} {
+		if err := s.Append(key, dto.Message{ID: "1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := s.Conversations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"#a/b", "#general", "@bob"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}